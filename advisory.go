@@ -0,0 +1,549 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	severityNone     = "none"
+	severityUnknown  = "unknown"
+	severityLow      = "low"
+	severityMedium   = "medium"
+	severityHigh     = "high"
+	severityCritical = "critical"
+)
+
+var severityRanks = map[string]int{
+	severityUnknown:  0,
+	severityLow:      1,
+	severityMedium:   2,
+	severityHigh:     3,
+	severityCritical: 4,
+}
+
+const osvEcosystemCocoaPods = "cocoapods"
+
+// Advisory is an OSV (https://ossf.github.io/osv-schema/) vulnerability entry.
+type Advisory struct {
+	ID               string                   `json:"id"`
+	Summary          string                   `json:"summary"`
+	Aliases          []string                 `json:"aliases"`
+	Severity         []osvSeverity            `json:"severity"`
+	Affected         []osvAffected            `json:"affected"`
+	DatabaseSpecific osvDatabaseSpecificModel `json:"database_specific"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges           []osvRange               `json:"ranges"`
+	Versions         []string                 `json:"versions"`
+	Severity         []osvSeverity            `json:"severity"`
+	DatabaseSpecific osvDatabaseSpecificModel `json:"database_specific"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+type osvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+type osvDatabaseSpecificModel struct {
+	Severity string `json:"severity"`
+}
+
+// AdvisoryFinding is a locked pod affected by an advisory.
+type AdvisoryFinding struct {
+	Pod           string
+	Version       string
+	AdvisoryID    string
+	Summary       string
+	Severity      string
+	FixedVersions []string
+}
+
+// readAdvisories reads the OSV advisories from a JSON file or from the JSON files of a directory (recursively).
+// A file contains a single advisory or a list of advisories.
+func readAdvisories(pth string) ([]Advisory, error) {
+	info, err := os.Stat(pth)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return readAdvisoryFile(pth)
+	}
+
+	var advisories []Advisory
+	err = filepath.WalkDir(pth, func(pth string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(pth) != ".json" {
+			return nil
+		}
+
+		fileAdvisories, err := readAdvisoryFile(pth)
+		if err != nil {
+			return err
+		}
+		advisories = append(advisories, fileAdvisories...)
+		return nil
+	})
+	return advisories, err
+}
+
+func readAdvisoryFile(pth string) ([]Advisory, error) {
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, err
+	}
+
+	var advisories []Advisory
+	if strings.HasPrefix(strings.TrimSpace(string(content)), "[") {
+		err = json.Unmarshal(content, &advisories)
+	} else {
+		var advisory Advisory
+		err = json.Unmarshal(content, &advisory)
+		advisories = append(advisories, advisory)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", pth, err)
+	}
+	return advisories, nil
+}
+
+// scanAdvisories returns the locked root pods affected by the CocoaPods advisories,
+// in descending order of severity. An advisory is reported once per pod: the affected entries of the same pod
+// (for example a subspec and its root pod) are merged, with the highest severity and all the fixed versions.
+func scanAdvisories(lock PodfileLock, advisories []Advisory) []AdvisoryFinding {
+	versions := map[string]string{}
+	for _, pod := range lock.Pods {
+		root := podRootName(pod.Name)
+		if _, ok := versions[root]; !ok {
+			versions[root] = pod.Version
+		}
+	}
+
+	var findings []AdvisoryFinding
+	findingIndexes := map[[2]string]int{}
+	for _, advisory := range advisories {
+		for _, affected := range advisory.Affected {
+			if strings.ToLower(affected.Package.Ecosystem) != osvEcosystemCocoaPods {
+				continue
+			}
+
+			pod := podRootName(affected.Package.Name)
+			version, ok := versions[pod]
+			if !ok || !affected.isAffected(version) {
+				continue
+			}
+
+			severity := advisory.severity(affected)
+			key := [2]string{pod, advisory.ID}
+			if i, ok := findingIndexes[key]; ok {
+				if severityRanks[severity] > severityRanks[findings[i].Severity] {
+					findings[i].Severity = severity
+				}
+				for _, fixed := range affected.fixedVersions() {
+					findings[i].FixedVersions = appendUnique(findings[i].FixedVersions, fixed)
+				}
+				continue
+			}
+
+			findingIndexes[key] = len(findings)
+			findings = append(findings, AdvisoryFinding{
+				Pod:           pod,
+				Version:       version,
+				AdvisoryID:    advisory.ID,
+				Summary:       advisory.Summary,
+				Severity:      severity,
+				FixedVersions: affected.fixedVersions(),
+			})
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if severityRanks[findings[i].Severity] != severityRanks[findings[j].Severity] {
+			return severityRanks[findings[i].Severity] > severityRanks[findings[j].Severity]
+		}
+		return findings[i].Pod < findings[j].Pod
+	})
+	return findings
+}
+
+func (a osvAffected) isAffected(version string) bool {
+	for _, v := range a.Versions {
+		if v == version {
+			return true
+		}
+	}
+
+	for _, r := range a.Ranges {
+		if r.Type == "GIT" {
+			continue
+		}
+		if r.isAffected(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAffected evaluates the range events in version order, as described in the OSV schema.
+func (r osvRange) isAffected(version string) bool {
+	events := append([]osvEvent{}, r.Events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return compareEventVersions(events[i].version(), events[j].version()) < 0
+	})
+
+	affected := false
+	for _, event := range events {
+		switch {
+		case event.Introduced != "":
+			if event.Introduced == "0" || comparePodVersions(version, event.Introduced) >= 0 {
+				affected = true
+			}
+		case event.Fixed != "":
+			if comparePodVersions(version, event.Fixed) >= 0 {
+				affected = false
+			}
+		case event.LastAffected != "":
+			if comparePodVersions(version, event.LastAffected) > 0 {
+				affected = false
+			}
+		case event.Limit != "":
+			if comparePodVersions(version, event.Limit) >= 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+func (e osvEvent) version() string {
+	for _, v := range []string{e.Introduced, e.Fixed, e.LastAffected, e.Limit} {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func compareEventVersions(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "0":
+		return -1
+	case b == "0":
+		return 1
+	default:
+		return comparePodVersions(a, b)
+	}
+}
+
+func (a osvAffected) fixedVersions() []string {
+	var fixed []string
+	for _, r := range a.Ranges {
+		if r.Type == "GIT" {
+			continue
+		}
+		for _, event := range r.Events {
+			if event.Fixed != "" {
+				fixed = appendUnique(fixed, event.Fixed)
+			}
+		}
+	}
+	return fixed
+}
+
+// severity returns the severity of the advisory for the affected package:
+// the database specific severity (for example of GitHub advisories) or the severity of the CVSS v3 vector.
+func (a Advisory) severity(affected osvAffected) string {
+	for _, severity := range []string{affected.DatabaseSpecific.Severity, a.DatabaseSpecific.Severity} {
+		if normalized := normalizeSeverity(severity); normalized != "" {
+			return normalized
+		}
+	}
+
+	for _, severity := range append(append([]osvSeverity{}, affected.Severity...), a.Severity...) {
+		if severity.Type != "CVSS_V3" {
+			continue
+		}
+		if score, ok := cvss3BaseScore(severity.Score); ok {
+			return cvssSeverity(score)
+		}
+	}
+
+	return severityUnknown
+}
+
+func normalizeSeverity(severity string) string {
+	switch strings.ToLower(severity) {
+	case "low":
+		return severityLow
+	case "moderate", "medium":
+		return severityMedium
+	case "high":
+		return severityHigh
+	case "critical":
+		return severityCritical
+	default:
+		return ""
+	}
+}
+
+func cvssSeverity(score float64) string {
+	switch {
+	case score >= 9.0:
+		return severityCritical
+	case score >= 7.0:
+		return severityHigh
+	case score >= 4.0:
+		return severityMedium
+	case score > 0:
+		return severityLow
+	default:
+		return severityNone
+	}
+}
+
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvss3BaseScore calculates the base score of a CVSS v3.x vector, for example: CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H.
+func cvss3BaseScore(vector string) (float64, bool) {
+	parts := strings.Split(vector, "/")
+	if len(parts) < 2 || !strings.HasPrefix(parts[0], "CVSS:3") {
+		return 0, false
+	}
+
+	metrics := map[string]string{}
+	for _, part := range parts[1:] {
+		key, value, found := strings.Cut(part, ":")
+		if !found {
+			return 0, false
+		}
+		metrics[key] = value
+	}
+
+	values := map[string]float64{}
+	for metric, weights := range cvss3Weights {
+		weight, ok := weights[metrics[metric]]
+		if !ok {
+			return 0, false
+		}
+		values[metric] = weight
+	}
+
+	scopeChanged := metrics["S"] == "C"
+	if !scopeChanged && metrics["S"] != "U" {
+		return 0, false
+	}
+
+	var privileges float64
+	switch metrics["PR"] {
+	case "N":
+		privileges = 0.85
+	case "L":
+		privileges = 0.62
+		if scopeChanged {
+			privileges = 0.68
+		}
+	case "H":
+		privileges = 0.27
+		if scopeChanged {
+			privileges = 0.5
+		}
+	default:
+		return 0, false
+	}
+
+	iss := 1 - (1-values["C"])*(1-values["I"])*(1-values["A"])
+	impact := 6.42 * iss
+	if scopeChanged {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+
+	exploitability := 8.22 * values["AV"] * values["AC"] * privileges * values["UI"]
+	if scopeChanged {
+		return cvssRoundUp(math.Min(1.08*(impact+exploitability), 10)), true
+	}
+	return cvssRoundUp(math.Min(impact+exploitability, 10)), true
+}
+
+// cvssRoundUp rounds up to one decimal, as defined in the CVSS v3.1 specification.
+func cvssRoundUp(value float64) float64 {
+	i := int(math.Round(value * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return (math.Floor(float64(i)/10000) + 1) / 10
+}
+
+// comparePodVersions compares two pod versions segment by segment, numeric segments are compared as numbers.
+// A pre-release version (for example 1.0.0-beta.1) is lower than the release version.
+func comparePodVersions(a, b string) int {
+	aRelease, aPre, _ := strings.Cut(a, "-")
+	bRelease, bPre, _ := strings.Cut(b, "-")
+
+	if c := compareVersionSegments(strings.Split(aRelease, "."), strings.Split(bRelease, ".")); c != 0 {
+		return c
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	default:
+		return compareVersionSegments(strings.Split(aPre, "."), strings.Split(bPre, "."))
+	}
+}
+
+func compareVersionSegments(a, b []string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		aSegment, bSegment := "0", "0"
+		if i < len(a) {
+			aSegment = a[i]
+		}
+		if i < len(b) {
+			bSegment = b[i]
+		}
+
+		aNumber, aErr := strconv.Atoi(aSegment)
+		bNumber, bErr := strconv.Atoi(bSegment)
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNumber != bNumber {
+				if aNumber < bNumber {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aSegment, bSegment); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+// isSeverityAtLeast tells if the severity reaches the threshold, the unknown severity never does.
+func isSeverityAtLeast(severity, threshold string) bool {
+	if threshold == severityNone || severity == severityUnknown || severity == severityNone {
+		return false
+	}
+	return severityRanks[severity] >= severityRanks[threshold]
+}
+
+// advisoryAnnotation returns the markdown table of the findings.
+func advisoryAnnotation(podCount int, findings []AdvisoryFinding) string {
+	var b strings.Builder
+	b.WriteString("### CocoaPods vulnerabilities\n")
+	if len(findings) == 0 {
+		fmt.Fprintf(&b, "No known vulnerabilities in the %d pods.\n", podCount)
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "%d advisories affect the installed pods.\n", len(findings))
+	b.WriteString("\n| Pod | Version | Advisory | Severity | Fixed in |\n| --- | --- | --- | --- | --- |\n")
+	for _, finding := range findings {
+		fixed := strings.Join(finding.FixedVersions, ", ")
+		if fixed == "" {
+			fixed = "-"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", finding.Pod, finding.Version, finding.AdvisoryID, finding.Severity, fixed)
+	}
+	return b.String()
+}
+
+// scanVulnerabilities matches the locked pods against the OSV advisories of the advisory_database input,
+// without any network access.
+func (s Step) scanVulnerabilities(config Config, lock PodfileLock) error {
+	if config.AdvisoryDatabase == "" {
+		return nil
+	}
+
+	s.logger.Printf("")
+	s.logger.Infof("Checking pods against the advisory database")
+
+	advisories, err := readAdvisories(config.AdvisoryDatabase)
+	if err != nil {
+		return fmt.Errorf("failed to read advisory database: %w", err)
+	}
+	s.logger.Printf("%d advisories read from %s", len(advisories), config.AdvisoryDatabase)
+
+	podCount := len(lock.RootPodNames())
+	findings := scanAdvisories(lock, advisories)
+
+	var failing []string
+	for _, finding := range findings {
+		fixed := "no fixed version"
+		if len(finding.FixedVersions) > 0 {
+			fixed = "fixed in " + strings.Join(finding.FixedVersions, ", ")
+		}
+		s.logger.Warnf("%s %s: %s (%s, %s) %s", finding.Pod, finding.Version, finding.AdvisoryID, finding.Severity, fixed, finding.Summary)
+
+		if isSeverityAtLeast(finding.Severity, config.FailOnSeverity) {
+			failing = append(failing, fmt.Sprintf("%s %s (%s)", finding.Pod, finding.AdvisoryID, finding.Severity))
+		}
+	}
+
+	style := "info"
+	if len(failing) > 0 {
+		style = "error"
+	} else if len(findings) > 0 {
+		style = "warning"
+	}
+	addAnnotation(s.cmdFactory, advisoryAnnotation(podCount, findings), style, "cocoapods-vulnerabilities")
+
+	if len(findings) == 0 {
+		s.logger.Donef("No known vulnerabilities in %d pods", podCount)
+		return nil
+	}
+
+	if len(failing) > 0 {
+		return withErrorCategory(errorCategoryVulnerability, fmt.Errorf("vulnerabilities with %s or higher severity found: %s", config.FailOnSeverity, strings.Join(failing, ", ")))
+	}
+
+	return nil
+}
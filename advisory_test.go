@@ -0,0 +1,177 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"bitrise-steplib/steps-cocoapods-install/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const alamofireAdvisoryContent = `{
+  "id": "GHSA-0000-0000-0001",
+  "summary": "Alamofire follows redirects to untrusted hosts",
+  "affected": [{
+    "package": {"ecosystem": "CocoaPods", "name": "Alamofire"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "5.0.0"}, {"fixed": "5.6.5"}]}]
+  }],
+  "database_specific": {"severity": "MODERATE"}
+}`
+
+const firebaseAdvisoriesContent = `[
+  {
+    "id": "OSV-2024-1",
+    "summary": "Remote code execution in FirebaseCore",
+    "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}],
+    "affected": [{
+      "package": {"ecosystem": "CocoaPods", "name": "FirebaseCore"},
+      "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"last_affected": "10.0.0"}]}]
+    }]
+  },
+  {
+    "id": "OSV-2024-2",
+    "summary": "Old Firebase issue",
+    "affected": [{
+      "package": {"ecosystem": "CocoaPods", "name": "Firebase"},
+      "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "9.0.0"}]}]
+    }]
+  },
+  {
+    "id": "GHSA-npm",
+    "affected": [{"package": {"ecosystem": "npm", "name": "Alamofire"}, "versions": ["5.6.4"]}]
+  }
+]`
+
+func Test_GivenAdvisoryDirectory_WhenScanning_ThenReportsAffectedPods(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(podfileLockWithSourcesContent))
+	require.NoError(t, err)
+
+	dir := createTestProject(t, map[string]string{
+		"advisories/alamofire.json":     alamofireAdvisoryContent,
+		"advisories/firebase/all.json":  firebaseAdvisoriesContent,
+		"advisories/firebase/README.md": "not an advisory",
+	})
+
+	// When
+	advisories, err := readAdvisories(filepath.Join(dir, "advisories"))
+	require.NoError(t, err)
+	findings := scanAdvisories(lock, advisories)
+
+	// Then
+	require.Len(t, advisories, 4)
+	require.Equal(t, []AdvisoryFinding{
+		{Pod: "FirebaseCore", Version: "10.0.0", AdvisoryID: "OSV-2024-1", Summary: "Remote code execution in FirebaseCore", Severity: severityCritical},
+		{Pod: "Alamofire", Version: "5.6.4", AdvisoryID: "GHSA-0000-0000-0001", Summary: "Alamofire follows redirects to untrusted hosts", Severity: severityMedium, FixedVersions: []string{"5.6.5"}},
+	}, findings)
+}
+
+func Test_GivenAdvisoryWithSeveralAffectedEntriesOfPod_WhenScanning_ThenReportsItOnce(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(podfileLockWithSourcesContent))
+	require.NoError(t, err)
+
+	dir := createTestProject(t, map[string]string{"advisories.json": `{
+  "id": "GHSA-0000-0000-0002",
+  "summary": "Alamofire leaks credentials",
+  "affected": [
+    {
+      "package": {"ecosystem": "CocoaPods", "name": "Alamofire"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "5.0.0"}, {"fixed": "5.6.5"}]}]
+    },
+    {
+      "package": {"ecosystem": "CocoaPods", "name": "Alamofire/Core"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "5.6.0"}, {"fixed": "5.7.0"}]}]
+    }
+  ],
+  "database_specific": {"severity": "HIGH"}
+}`})
+
+	// When
+	advisories, err := readAdvisories(filepath.Join(dir, "advisories.json"))
+	require.NoError(t, err)
+	findings := scanAdvisories(lock, advisories)
+
+	// Then
+	require.Equal(t, []AdvisoryFinding{
+		{Pod: "Alamofire", Version: "5.6.4", AdvisoryID: "GHSA-0000-0000-0002", Summary: "Alamofire leaks credentials", Severity: severityHigh, FixedVersions: []string{"5.6.5", "5.7.0"}},
+	}, findings)
+}
+
+func Test_GivenCVSSVectors_WhenCalculatingBaseScore_ThenMatchesSpecification(t *testing.T) {
+	tests := []struct {
+		vector string
+		want   float64
+	}{
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", want: 9.8},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", want: 10.0},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", want: 6.1},
+		{vector: "CVSS:3.0/AV:L/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N", want: 1.8},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.vector, func(t *testing.T) {
+			score, ok := cvss3BaseScore(tt.vector)
+			require.True(t, ok)
+			require.Equal(t, tt.want, score)
+		})
+	}
+
+	_, ok := cvss3BaseScore("CVSS:2.0/AV:N/AC:L/Au:N/C:P/I:P/A:P")
+	require.False(t, ok)
+}
+
+func Test_GivenPodVersions_WhenComparing_ThenOrdersSegmentsNumerically(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "5.6.4", b: "5.6.5", want: -1},
+		{a: "5.10.0", b: "5.9.0", want: 1},
+		{a: "1.0", b: "1.0.0", want: 0},
+		{a: "1.0.0-beta.2", b: "1.0.0", want: -1},
+		{a: "1.0.0-beta.10", b: "1.0.0-beta.2", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			require.Equal(t, tt.want, comparePodVersions(tt.a, tt.b))
+		})
+	}
+}
+
+func Test_GivenSeverityThreshold_WhenComparing_ThenIgnoresUnknownSeverity(t *testing.T) {
+	require.True(t, isSeverityAtLeast(severityCritical, severityHigh))
+	require.True(t, isSeverityAtLeast(severityHigh, severityHigh))
+	require.False(t, isSeverityAtLeast(severityMedium, severityHigh))
+	require.False(t, isSeverityAtLeast(severityUnknown, severityLow))
+	require.False(t, isSeverityAtLeast(severityCritical, severityNone))
+}
+
+func Test_GivenCriticalVulnerability_WhenScanning_ThenFailsOverThreshold(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(podfileLockWithSourcesContent))
+	require.NoError(t, err)
+	dir := createTestProject(t, map[string]string{"advisories.json": firebaseAdvisoriesContent})
+
+	cmdFactory := new(mocks.CommandFactory)
+	annotateCmd := new(mocks.Command)
+	annotateCmd.On("Run").Return(nil).Once()
+	cmdFactory.On("Create", "bitrise", mock.MatchedBy(func(args []string) bool {
+		return containsAll(args, []string{"--style", "error", "--context", "cocoapods-vulnerabilities"})
+	}), mock.Anything).Return(annotateCmd).Once()
+
+	step := createTestStep(cmdFactory, fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	err = step.scanVulnerabilities(Config{
+		AdvisoryDatabase: filepath.Join(dir, "advisories.json"),
+		FailOnSeverity:   severityHigh,
+	}, lock)
+
+	// Then
+	require.EqualError(t, err, "vulnerabilities with high or higher severity found: FirebaseCore OSV-2024-1 (critical)")
+	require.Equal(t, errorCategoryVulnerability, errorCategory(err))
+	cmdFactory.AssertExpectations(t)
+}
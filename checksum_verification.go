@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const checksumMissing = "missing"
+
+// ChecksumMismatch is a pod whose podspec checksum differs from the expected one.
+type ChecksumMismatch struct {
+	Pod      string
+	Expected string
+	Actual   string
+	// Source is the file the actual checksum comes from.
+	Source string
+}
+
+func (m ChecksumMismatch) String() string {
+	expected := m.Expected
+	if expected == "" {
+		expected = checksumMissing
+	}
+	return fmt.Sprintf("%s: expected %s, got %s (%s)", m.Pod, expected, m.Actual, m.Source)
+}
+
+// verifyManifestChecksums compares the SPEC CHECKSUMS of Pods/Manifest.lock with the expected checksums.
+func verifyManifestChecksums(expected map[string]string, manifest PodfileLock) []ChecksumMismatch {
+	const source = "Pods/Manifest.lock"
+
+	var mismatches []ChecksumMismatch
+	for _, pod := range sortedKeys(expected) {
+		actual, ok := manifest.SpecChecksums[pod]
+		if !ok {
+			actual = checksumMissing
+		}
+		if actual != expected[pod] {
+			mismatches = append(mismatches, ChecksumMismatch{Pod: pod, Expected: expected[pod], Actual: actual, Source: source})
+		}
+	}
+	for _, pod := range sortedKeys(manifest.SpecChecksums) {
+		if _, ok := expected[pod]; !ok {
+			mismatches = append(mismatches, ChecksumMismatch{Pod: pod, Actual: manifest.SpecChecksums[pod], Source: source})
+		}
+	}
+	return mismatches
+}
+
+// verifyLocalPodspecChecksums recomputes the checksum (SHA-1 of the podspec file) of the pods from external sources,
+// using the podspecs stored in Pods/Local Podspecs, and compares them with the expected checksums.
+// The podspec of a :path pod can also be the original podspec file in the pod's directory.
+func verifyLocalPodspecChecksums(podfileDir string, lock PodfileLock, expected map[string]string) ([]ChecksumMismatch, error) {
+	podsDir := filepath.Join(podfileDir, "Pods")
+
+	var mismatches []ChecksumMismatch
+	for _, pod := range sortedKeys(lock.ExternalSources) {
+		expectedChecksum, ok := expected[pod]
+		if !ok {
+			continue
+		}
+
+		candidates := []string{
+			filepath.Join(podsDir, "Local Podspecs", pod+".podspec.json"),
+			filepath.Join(podsDir, "Local Podspecs", pod+".podspec"),
+		}
+		if _, ok := lock.ExternalSources[pod][":path"]; ok {
+			dir := podDir(podfileDir, podsDir, lock, pod)
+			candidates = append(candidates, filepath.Join(dir, pod+".podspec"), filepath.Join(dir, pod+".podspec.json"))
+		}
+
+		var mismatch *ChecksumMismatch
+		for _, pth := range candidates {
+			checksum, err := fileSHA1(pth)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to calculate checksum of %s: %w", pth, err)
+			}
+
+			if checksum == expectedChecksum {
+				mismatch = nil
+				break
+			}
+			if mismatch == nil {
+				rel, err := filepath.Rel(podfileDir, pth)
+				if err != nil {
+					rel = pth
+				}
+				mismatch = &ChecksumMismatch{Pod: pod, Expected: expectedChecksum, Actual: checksum, Source: rel}
+			}
+		}
+
+		if mismatch != nil {
+			mismatches = append(mismatches, *mismatch)
+		}
+	}
+	return mismatches, nil
+}
+
+func fileSHA1(pth string) (string, error) {
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// verifyExistingPodChecksums checks the Pods directory present before pod install (restored from the cache or committed):
+// the local podspecs need to match the checksums recorded in Pods/Manifest.lock.
+// Pods/Manifest.lock itself may differ from Podfile.lock, pod install updates the changed pods.
+func (s Step) verifyExistingPodChecksums(config Config) error {
+	s.logger.Printf("")
+	s.logger.Infof("Verifying pod checksums of the existing Pods directory")
+
+	manifest, err := readPodfileLock(filepath.Join(config.PodfileDir, "Pods", "Manifest.lock"))
+	if err != nil {
+		return fmt.Errorf("failed to read Pods/Manifest.lock: %w", err)
+	}
+
+	mismatches, err := verifyLocalPodspecChecksums(config.PodfileDir, manifest, manifest.SpecChecksums)
+	if err != nil {
+		return err
+	}
+
+	return s.reportChecksumMismatches(mismatches, "the cached Pods directory is stale or has been modified")
+}
+
+// verifyInstalledPodChecksums checks the installed Pods directory against the committed Podfile.lock (read before pod install):
+// the checksums of Pods/Manifest.lock and the recomputed checksums of the local podspecs need to match the lock.
+func (s Step) verifyInstalledPodChecksums(config Config, committedLock *PodfileLock) error {
+	s.logger.Printf("")
+	s.logger.Infof("Verifying pod checksums against Podfile.lock")
+
+	if committedLock == nil {
+		s.logger.Warnf("No committed Podfile.lock, the installed pod checksums can not be verified")
+		return nil
+	}
+	if config.Command == "update" {
+		s.logger.Printf("pod update updates Podfile.lock, the installed pod checksums are not verified")
+		return nil
+	}
+	lock := *committedLock
+
+	manifest, err := readPodfileLock(filepath.Join(config.PodfileDir, "Pods", "Manifest.lock"))
+	if err != nil {
+		return fmt.Errorf("failed to read Pods/Manifest.lock: %w", err)
+	}
+
+	mismatches := verifyManifestChecksums(lock.SpecChecksums, manifest)
+	localMismatches, err := verifyLocalPodspecChecksums(config.PodfileDir, lock, lock.SpecChecksums)
+	if err != nil {
+		return err
+	}
+	mismatches = append(mismatches, localMismatches...)
+
+	return s.reportChecksumMismatches(mismatches, "the installed Pods do not match Podfile.lock")
+}
+
+func (s Step) reportChecksumMismatches(mismatches []ChecksumMismatch, reason string) error {
+	if len(mismatches) == 0 {
+		s.logger.Donef("Pod checksums match")
+		return nil
+	}
+
+	var items []string
+	for _, mismatch := range mismatches {
+		items = append(items, mismatch.String())
+	}
+	return fmt.Errorf("pod checksum mismatch, %s:\n%s", reason, strings.Join(items, "\n"))
+}
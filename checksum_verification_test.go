@@ -0,0 +1,173 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"bitrise-steplib/steps-cocoapods-install/mocks"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	localKitPodspecContent   = `{"name": "LocalKit", "version": "0.1.0"}`
+	swiftyJSONPodspecContent = `{"name": "SwiftyJSON", "version": "5.0.1"}`
+)
+
+func sha1Hex(content string) string {
+	sum := sha1.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func checksumLockContent(alamofireChecksum, localKitChecksum, swiftyJSONChecksum string) string {
+	return fmt.Sprintf(`PODS:
+  - Alamofire (5.6.4)
+  - LocalKit (0.1.0)
+  - SwiftyJSON (5.0.1)
+
+DEPENDENCIES:
+  - Alamofire (~> 5.6)
+  - LocalKit (from `+"`../LocalKit`"+`)
+  - SwiftyJSON (from `+"`https://github.com/SwiftyJSON/SwiftyJSON.git`"+`, branch `+"`master`"+`)
+
+SPEC REPOS:
+  trunk:
+    - Alamofire
+
+EXTERNAL SOURCES:
+  LocalKit:
+    :path: "../LocalKit"
+  SwiftyJSON:
+    :branch: master
+    :git: https://github.com/SwiftyJSON/SwiftyJSON.git
+
+SPEC CHECKSUMS:
+  Alamofire: %s
+  LocalKit: %s
+  SwiftyJSON: %s
+
+COCOAPODS: 1.11.3
+`, alamofireChecksum, localKitChecksum, swiftyJSONChecksum)
+}
+
+func Test_GivenMatchingManifest_WhenVerifyingManifestChecksums_ThenNoMismatches(t *testing.T) {
+	// Given
+	content := checksumLockContent("4e95d97098eacb88856099c4fc79b526a299e48c", sha1Hex(localKitPodspecContent), sha1Hex(swiftyJSONPodspecContent))
+	lock, err := parsePodfileLock([]byte(content))
+	require.NoError(t, err)
+
+	// When
+	mismatches := verifyManifestChecksums(lock.SpecChecksums, lock)
+
+	// Then
+	require.Empty(t, mismatches)
+}
+
+func Test_GivenStaleManifest_WhenVerifyingManifestChecksums_ThenListsChangedAndMissingPods(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(checksumLockContent("aaaa", "bbbb", "cccc")))
+	require.NoError(t, err)
+	manifest := PodfileLock{SpecChecksums: map[string]string{
+		"Alamofire":  "aaaa",
+		"LocalKit":   "dddd",
+		"Kingfisher": "eeee",
+	}}
+
+	// When
+	mismatches := verifyManifestChecksums(lock.SpecChecksums, manifest)
+
+	// Then
+	require.Equal(t, []ChecksumMismatch{
+		{Pod: "LocalKit", Expected: "bbbb", Actual: "dddd", Source: "Pods/Manifest.lock"},
+		{Pod: "SwiftyJSON", Expected: "cccc", Actual: checksumMissing, Source: "Pods/Manifest.lock"},
+		{Pod: "Kingfisher", Actual: "eeee", Source: "Pods/Manifest.lock"},
+	}, mismatches)
+	require.Equal(t, "Kingfisher: expected missing, got eeee (Pods/Manifest.lock)", mismatches[2].String())
+}
+
+func Test_GivenLocalPodspecs_WhenVerifyingLocalPodspecChecksums_ThenRecomputesChecksums(t *testing.T) {
+	// Given
+	dir := createTestProject(t, map[string]string{
+		"ios/Pods/Local Podspecs/LocalKit.podspec.json":   localKitPodspecContent,
+		"ios/Pods/Local Podspecs/SwiftyJSON.podspec.json": `{"name": "SwiftyJSON", "version": "5.0.1", "source_files": "Tampered/*.swift"}`,
+	})
+	content := checksumLockContent("4e95d97098eacb88856099c4fc79b526a299e48c", sha1Hex(localKitPodspecContent), sha1Hex(swiftyJSONPodspecContent))
+	lock, err := parsePodfileLock([]byte(content))
+	require.NoError(t, err)
+
+	// When
+	mismatches, err := verifyLocalPodspecChecksums(filepath.Join(dir, "ios"), lock, lock.SpecChecksums)
+
+	// Then
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+	require.Equal(t, "SwiftyJSON", mismatches[0].Pod)
+	require.Equal(t, sha1Hex(swiftyJSONPodspecContent), mismatches[0].Expected)
+	require.Equal(t, filepath.Join("Pods", "Local Podspecs", "SwiftyJSON.podspec.json"), mismatches[0].Source)
+}
+
+func Test_GivenPathPodWithOriginalPodspec_WhenVerifyingLocalPodspecChecksums_ThenAcceptsOriginalPodspec(t *testing.T) {
+	// Given
+	podspec := "Pod::Spec.new do |s|\n  s.name = 'LocalKit'\nend\n"
+	dir := createTestProject(t, map[string]string{
+		"ios/Pods/Local Podspecs/LocalKit.podspec.json": localKitPodspecContent,
+		"LocalKit/LocalKit.podspec":                     podspec,
+	})
+	lock, err := parsePodfileLock([]byte(checksumLockContent("aaaa", sha1Hex(podspec), "cccc")))
+	require.NoError(t, err)
+
+	// When
+	mismatches, err := verifyLocalPodspecChecksums(filepath.Join(dir, "ios"), lock, lock.SpecChecksums)
+
+	// Then
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+}
+
+func Test_GivenTamperedInstall_WhenVerifyingInstalledPodChecksums_ThenFailsWithAffectedPods(t *testing.T) {
+	// Given
+	content := checksumLockContent("4e95d97098eacb88856099c4fc79b526a299e48c", sha1Hex(localKitPodspecContent), sha1Hex(swiftyJSONPodspecContent))
+	dir := createTestProject(t, map[string]string{
+		"Podfile.lock":       content,
+		"Pods/Manifest.lock": content,
+		"Pods/Local Podspecs/LocalKit.podspec.json": `{"name": "LocalKit", "version": "0.2.0"}`,
+	})
+
+	committedLock, err := parsePodfileLock([]byte(content))
+	require.NoError(t, err)
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	err = step.verifyInstalledPodChecksums(Config{PodfileDir: dir, VerifyPodChecksums: true}, &committedLock)
+
+	// Then
+	require.EqualError(t, err, fmt.Sprintf("pod checksum mismatch, the installed Pods do not match Podfile.lock:\nLocalKit: expected %s, got %s (%s)",
+		sha1Hex(localKitPodspecContent), sha1Hex(`{"name": "LocalKit", "version": "0.2.0"}`), filepath.Join("Pods", "Local Podspecs", "LocalKit.podspec.json")))
+}
+
+func Test_GivenInstallRewroteTheLockfile_WhenVerifyingInstalledPodChecksums_ThenComparesWithCommittedLock(t *testing.T) {
+	// Given
+	committedContent := checksumLockContent("4e95d97098eacb88856099c4fc79b526a299e48c", sha1Hex(localKitPodspecContent), sha1Hex(swiftyJSONPodspecContent))
+	installedContent := checksumLockContent("ffffffffffffffffffffffffffffffffffffffff", sha1Hex(localKitPodspecContent), sha1Hex(swiftyJSONPodspecContent))
+	dir := createTestProject(t, map[string]string{
+		"Podfile.lock":       installedContent,
+		"Pods/Manifest.lock": installedContent,
+		"Pods/Local Podspecs/LocalKit.podspec.json":   localKitPodspecContent,
+		"Pods/Local Podspecs/SwiftyJSON.podspec.json": swiftyJSONPodspecContent,
+	})
+	committedLock, err := parsePodfileLock([]byte(committedContent))
+	require.NoError(t, err)
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	err = step.verifyInstalledPodChecksums(Config{PodfileDir: dir, VerifyPodChecksums: true}, &committedLock)
+
+	// Then
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "expected 4e95d97098eacb88856099c4fc79b526a299e48c, got ffffffffffffffffffffffffffffffffffffffff")
+}
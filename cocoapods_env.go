@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/bitrise-io/go-utils/v2/env"
+)
+
+const utf8Locale = "en_US.UTF-8"
+
+var secretEnvKeyParts = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "AUTH", "CREDENTIAL", "PRIVATE", "KEY"}
+
+// cocoapodsEnvs returns the environment variables set for every pod and bundle command, on top of the Step's environment.
+// CocoaPods fails with "Unicode Normalization not appropriate for ASCII-8BIT" if the locale is not UTF-8,
+// and sends usage stats unless disabled. The extraEnvs are added last, to override the defaults.
+func cocoapodsEnvs(envRepository env.Repository, cpHomeDir string, extraEnvs []string) []string {
+	extra := map[string]string{}
+	for _, e := range extraEnvs {
+		key, value, _ := strings.Cut(e, "=")
+		extra[key] = value
+	}
+	lookup := func(key string) string {
+		if value, ok := extra[key]; ok {
+			return value
+		}
+		return envRepository.Get(key)
+	}
+
+	var envs []string
+	for _, key := range []string{"LANG", "LC_ALL"} {
+		if !isUTF8Locale(lookup(key)) {
+			envs = append(envs, key+"="+utf8Locale)
+		}
+	}
+	if lookup("COCOAPODS_DISABLE_STATS") == "" {
+		envs = append(envs, "COCOAPODS_DISABLE_STATS=true")
+	}
+	if cpHomeDir != "" {
+		envs = append(envs, "CP_HOME_DIR="+cpHomeDir)
+	}
+
+	return append(envs, extraEnvs...)
+}
+
+func isUTF8Locale(locale string) bool {
+	locale = strings.ToUpper(locale)
+	return strings.Contains(locale, "UTF-8") || strings.Contains(locale, "UTF8")
+}
+
+// redactedEnvs returns the envs in a loggable form, hiding the values of secret looking keys.
+func redactedEnvs(envs []string) []string {
+	var redacted []string
+	for _, e := range envs {
+		key, value, _ := strings.Cut(e, "=")
+		if value != "" && isSecretEnvKey(key) {
+			value = "[REDACTED]"
+		}
+		redacted = append(redacted, key+"="+value)
+	}
+	return redacted
+}
+
+func isSecretEnvKey(key string) bool {
+	key = strings.ToUpper(key)
+	for _, part := range secretEnvKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
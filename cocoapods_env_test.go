@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/env"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenStepEnvironment_WhenCreatingCocoapodsEnvs_ThenAddsSafeDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		envs      map[string]string
+		cpHomeDir string
+		extraEnvs []string
+		want      []string
+	}{
+		{
+			name: "missing locale and stats setting",
+			envs: map[string]string{"LANG": "", "LC_ALL": "", "COCOAPODS_DISABLE_STATS": ""},
+			want: []string{"LANG=en_US.UTF-8", "LC_ALL=en_US.UTF-8", "COCOAPODS_DISABLE_STATS=true"},
+		},
+		{
+			name: "non UTF-8 locale",
+			envs: map[string]string{"LANG": "C", "LC_ALL": "en_US.ISO8859-1", "COCOAPODS_DISABLE_STATS": ""},
+			want: []string{"LANG=en_US.UTF-8", "LC_ALL=en_US.UTF-8", "COCOAPODS_DISABLE_STATS=true"},
+		},
+		{
+			name: "already configured",
+			envs: map[string]string{"LANG": "de_DE.UTF-8", "LC_ALL": "C.utf8", "COCOAPODS_DISABLE_STATS": "false"},
+		},
+		{
+			name:      "CP_HOME_DIR and extra envs",
+			envs:      map[string]string{"LANG": "", "LC_ALL": "en_US.UTF-8", "COCOAPODS_DISABLE_STATS": ""},
+			cpHomeDir: "/tmp/cocoapods",
+			extraEnvs: []string{"LANG=hu_HU.UTF-8", "COCOAPODS_DISABLE_STATS=false", "FOO=bar"},
+			want:      []string{"CP_HOME_DIR=/tmp/cocoapods", "LANG=hu_HU.UTF-8", "COCOAPODS_DISABLE_STATS=false", "FOO=bar"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envs {
+				t.Setenv(key, value)
+			}
+
+			got := cocoapodsEnvs(env.NewRepository(), tt.cpHomeDir, tt.extraEnvs)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_GivenEnvs_WhenRedacting_ThenHidesSecretValues(t *testing.T) {
+	envs := []string{"LANG=en_US.UTF-8", "GITHUB_TOKEN=ghp_123", "ArtifactoryPassword=secret", "AWS_ACCESS_KEY_ID=AKIA", "EMPTY_SECRET="}
+
+	got := redactedEnvs(envs)
+
+	require.Equal(t, []string{"LANG=en_US.UTF-8", "GITHUB_TOKEN=[REDACTED]", "ArtifactoryPassword=[REDACTED]", "AWS_ACCESS_KEY_ID=[REDACTED]", "EMPTY_SECRET="}, got)
+}
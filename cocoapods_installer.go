@@ -3,10 +3,10 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
-	"github.com/bitrise-io/go-steputils/v2/ruby"
 	"github.com/bitrise-io/go-utils/v2/command"
 	"github.com/bitrise-io/go-utils/v2/errorutil"
 	"github.com/bitrise-io/go-utils/v2/log"
@@ -14,62 +14,86 @@ import (
 
 // CocoapodsInstaller ...
 type CocoapodsInstaller struct {
-	rubyCmdFactory ruby.CommandFactory
-	logger         log.Logger
+	installCmdFactory    command.Factory
+	repoUpdateCmdFactory command.Factory
+	phaseTimer           *PhaseTimer
+	logger               log.Logger
 }
 
-// NewCocoapodsInstaller ...
-func NewCocoapodsInstaller(rubyCmdFactory ruby.CommandFactory, logger log.Logger) CocoapodsInstaller {
+// NewCocoapodsInstaller creates an installer, which runs pod install (or update) and pod repo update
+// with the given command factories, which can apply different timeouts for the two commands.
+func NewCocoapodsInstaller(installCmdFactory command.Factory, repoUpdateCmdFactory command.Factory, phaseTimer *PhaseTimer, logger log.Logger) CocoapodsInstaller {
 	return CocoapodsInstaller{
-		rubyCmdFactory: rubyCmdFactory,
-		logger:         logger,
+		installCmdFactory:    installCmdFactory,
+		repoUpdateCmdFactory: repoUpdateCmdFactory,
+		phaseTimer:           phaseTimer,
+		logger:               logger,
 	}
 }
 
-// InstallPods ...
-func (i CocoapodsInstaller) InstallPods(podArg []string, podCmd string, podfileDir string, verbose bool) error {
-	if err := i.runPodInstall(podArg, podCmd, podfileDir, verbose); err == nil {
-		return nil
+// InstallPods runs pod install (or update), and retries it once after a pod repo update on failure.
+// Returns the number of retries.
+func (i CocoapodsInstaller) InstallPods(podArg []string, podCmd string, extraArgs []string, workDir string, envs []string, verbose bool) (int, error) {
+	if err := i.runPodInstall(podArg, podCmd, extraArgs, workDir, envs, verbose); err == nil {
+		return 0, nil
 	} else {
 		i.logger.Printf("")
 		i.logger.Warnf(errorutil.FormattedError(fmt.Errorf("Failed to install Pods: %w", err)))
+		if isCommandTimeoutError(err) {
+			i.logger.Warnf("pod %s was killed by the timeout watchdog", podCmd)
+		}
 		i.logger.Warnf("Retrying with pod repo update...")
 		i.logger.Printf("")
 	}
 
-	if err := i.runPodRepoUpdate(podArg, podfileDir, verbose); err != nil {
-		return err
+	if err := i.runPodRepoUpdate(podArg, workDir, envs, verbose); err != nil {
+		return 1, err
 	}
 
-	if err := i.runPodInstall(podArg, podCmd, podfileDir, verbose); err != nil {
-		return err
+	if err := i.runPodInstall(podArg, podCmd, extraArgs, workDir, envs, verbose); err != nil {
+		return 1, err
 	}
 
-	return nil
+	return 1, nil
 }
 
-func (i CocoapodsInstaller) runPodInstall(podArg []string, podCmd string, podfileDir string, verbose bool) error {
+func (i CocoapodsInstaller) runPodInstall(podArg []string, podCmd string, extraArgs []string, workDir string, envs []string, verbose bool) error {
+	defer i.phaseTimer.Start(phasePodInstall)()
+
+	var stdout io.Writer = os.Stdout
+	// Pods are logged one by one only in verbose mode.
+	podTimingParser := NewPodTimingParser()
+	if verbose {
+		stdout = io.MultiWriter(os.Stdout, podTimingParser)
+	}
+
 	errorFinder := &cocoapodsCmdErrorFinder{}
-	cmdSlice := podInstallCmdSlice(podArg, podCmd, verbose)
-	cmd := createPodCommand(i.rubyCmdFactory, cmdSlice, podfileDir, errorFinder)
+	cmdSlice := podInstallCmdSlice(podArg, podCmd, extraArgs, verbose)
+	cmd := createPodCommand(i.installCmdFactory, cmdSlice, workDir, envs, stdout, errorFinder)
 	i.logger.Donef("$ %s", cmd.PrintableCommandArgs())
-	return cmd.Run()
+	err := cmd.Run()
+
+	i.phaseTimer.AddPodTimings(podTimingParser.Timings())
+
+	return err
 }
 
-func (i CocoapodsInstaller) runPodRepoUpdate(podArg []string, podfileDir string, verbose bool) error {
+func (i CocoapodsInstaller) runPodRepoUpdate(podArg []string, workDir string, envs []string, verbose bool) error {
+	defer i.phaseTimer.Start(phaseRepoUpdate)()
+
 	errorFinder := &cocoapodsCmdErrorFinder{}
 	cmdSlice := podRepoUpdateCmdSlice(podArg, verbose)
-	cmd := createPodCommand(i.rubyCmdFactory, cmdSlice, podfileDir, errorFinder)
+	cmd := createPodCommand(i.repoUpdateCmdFactory, cmdSlice, workDir, envs, os.Stdout, errorFinder)
 	i.logger.Donef("$ %s", cmd.PrintableCommandArgs())
 	return cmd.Run()
 }
 
-func podInstallCmdSlice(podArg []string, podCmd string, verbose bool) []string {
+func podInstallCmdSlice(podArg []string, podCmd string, extraArgs []string, verbose bool) []string {
 	cmdSlice := append(podArg, podCmd, "--no-repo-update")
 	if verbose {
 		cmdSlice = append(cmdSlice, "--verbose")
 	}
-	return cmdSlice
+	return append(cmdSlice, extraArgs...)
 }
 
 func podRepoUpdateCmdSlice(podArg []string, verbose bool) []string {
@@ -80,12 +104,12 @@ func podRepoUpdateCmdSlice(podArg []string, verbose bool) []string {
 	return cmdSlice
 }
 
-func createPodCommand(factory ruby.CommandFactory, args []string, dir string, errorFinder *cocoapodsCmdErrorFinder) command.Command {
+func createPodCommand(factory command.Factory, args []string, dir string, envs []string, stdout io.Writer, errorFinder *cocoapodsCmdErrorFinder) command.Command {
 	return factory.Create(args[0], args[1:], &command.Opts{
-		Stdout:      os.Stdout,
+		Stdout:      stdout,
 		Stderr:      os.Stderr,
 		Stdin:       nil,
-		Env:         nil,
+		Env:         envs,
 		Dir:         dir,
 		ErrorFinder: errorFinder.findErrors,
 	})
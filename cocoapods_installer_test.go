@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"bitrise-steplib/steps-cocoapods-install/mocks"
 
@@ -13,9 +14,10 @@ import (
 
 func Test_GivenCocoapodsInstaller_WhenArgsGiven_ThenRunsExpectedCommand(t *testing.T) {
 	type args struct {
-		podArg  []string
-		podCmd  string
-		verbose bool
+		podArg    []string
+		podCmd    string
+		extraArgs []string
+		verbose   bool
 	}
 	tests := []struct {
 		name    string
@@ -37,6 +39,11 @@ func Test_GivenCocoapodsInstaller_WhenArgsGiven_ThenRunsExpectedCommand(t *testi
 			args:    args{podArg: []string{"pod"}, podCmd: "update", verbose: true},
 			wantCmd: []string{"pod", "update", "--no-repo-update", "--verbose"},
 		},
+		{
+			name:    "pod install with extra args",
+			args:    args{podArg: []string{"pod"}, podCmd: "install", extraArgs: []string{"--clean-install", "--ansi"}, verbose: true},
+			wantCmd: []string{"pod", "install", "--no-repo-update", "--verbose", "--clean-install", "--ansi"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -51,13 +58,14 @@ func Test_GivenCocoapodsInstaller_WhenArgsGiven_ThenRunsExpectedCommand(t *testi
 			logger := new(mocks.Logger)
 			logger.On("Donef", mock.Anything, mock.Anything)
 
-			installer := NewCocoapodsInstaller(cmdFactory, logger)
+			installer := NewCocoapodsInstaller(cmdFactory, cmdFactory, NewPhaseTimer(), logger)
 
 			// When
-			err := installer.InstallPods(tt.args.podArg, tt.args.podCmd, "", tt.args.verbose)
+			retryCount, err := installer.InstallPods(tt.args.podArg, tt.args.podCmd, tt.args.extraArgs, "", nil, tt.args.verbose)
 
 			// Then
 			require.NoError(t, err)
+			require.Equal(t, 0, retryCount)
 			cmdFactory.AssertExpectations(t)
 			cmd.AssertExpectations(t)
 		})
@@ -91,19 +99,62 @@ func Test_GivenCocoapodsInstaller_WhenInstallFails_ThenRunsRepoUpdateAndRetries(
 	logger.On("Printf", mock.Anything, mock.Anything)
 	logger.On("Warnf", mock.Anything, mock.Anything)
 
-	installer := NewCocoapodsInstaller(cmdFactory, logger)
+	installer := NewCocoapodsInstaller(cmdFactory, cmdFactory, NewPhaseTimer(), logger)
 
 	// When
-	err := installer.InstallPods(podArg, podCmd, "", false)
+	retryCount, err := installer.InstallPods(podArg, podCmd, nil, "", nil, false)
 
 	// Then
 	require.NoError(t, err)
+	require.Equal(t, 1, retryCount)
 	cmdFactory.AssertExpectations(t)
 	firstInstallCmd.AssertExpectations(t)
 	repoUpdateCmd.AssertExpectations(t)
 	secondInstallCmd.AssertExpectations(t)
 }
 
+func Test_GivenCocoapodsInstaller_WhenInstallTimesOut_ThenRetries(t *testing.T) {
+	// Given
+	podArg := []string{"pod"}
+	podCmd := "install"
+
+	firstInstallCmd := new(mocks.Command)
+	firstInstallCmd.On("PrintableCommandArgs").Return(mock.Anything)
+	firstInstallCmd.On("Run").Return(&CommandTimeoutError{Command: "pod install", Kind: timeoutKindNoOutput, Timeout: time.Minute}).Once()
+
+	secondInstallCmd := new(mocks.Command)
+	secondInstallCmd.On("PrintableCommandArgs").Return(mock.Anything)
+	secondInstallCmd.On("Run").Return(nil).Once()
+
+	installCmdFactory := new(mocks.CommandFactory)
+	installCmdFactory.On("Create", podArg[0], []string{podCmd, "--no-repo-update"}, mock.Anything).Return(firstInstallCmd).Once()
+	installCmdFactory.On("Create", podArg[0], []string{podCmd, "--no-repo-update"}, mock.Anything).Return(secondInstallCmd).Once()
+
+	repoUpdateCmd := new(mocks.Command)
+	repoUpdateCmd.On("PrintableCommandArgs").Return(mock.Anything)
+	repoUpdateCmd.On("Run").Return(nil).Once()
+
+	repoUpdateCmdFactory := new(mocks.CommandFactory)
+	repoUpdateCmdFactory.On("Create", podArg[0], []string{"repo", "update"}, mock.Anything).Return(repoUpdateCmd).Once()
+
+	logger := new(mocks.Logger)
+	logger.On("Donef", mock.Anything, mock.Anything)
+	logger.On("Printf", mock.Anything, mock.Anything)
+	logger.On("Warnf", mock.Anything, mock.Anything)
+
+	installer := NewCocoapodsInstaller(installCmdFactory, repoUpdateCmdFactory, NewPhaseTimer(), logger)
+
+	// When
+	retryCount, err := installer.InstallPods(podArg, podCmd, nil, "", nil, false)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, 1, retryCount)
+	installCmdFactory.AssertExpectations(t)
+	repoUpdateCmdFactory.AssertExpectations(t)
+	logger.AssertCalled(t, "Warnf", "pod %s was killed by the timeout watchdog", podCmd)
+}
+
 func Test_GivenCocoapodsErrorFinder_WhenGatewayTimeOut_ThenFindsErrors(t *testing.T) {
 	expectedErrors := []string{
 		"[!] Error installing boost",
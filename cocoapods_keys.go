@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const cocoapodsKeysPlugin = "cocoapods-keys"
+
+var (
+	cocoapodsKeysPluginRegexp = regexp.MustCompile(`(?m)^\s*plugin\s*\(?\s*['"]cocoapods-keys['"]`)
+	cocoapodsKeysListRegexp   = regexp.MustCompile(`(?s)(?::keys\s*=>|\bkeys:)\s*\[(.*?)\]`)
+	quotedStringRegexp        = regexp.MustCompile(`['"]([^'"]+)['"]`)
+)
+
+// podfileCocoapodsKeys returns the keys of the Podfile's cocoapods-keys plugin declaration, for example:
+// plugin 'cocoapods-keys', { :project => 'App', :keys => ['AnalyticsKey'] }.
+func podfileCocoapodsKeys(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := cocoapodsKeysPluginRegexp.FindIndex(content)
+	if loc == nil {
+		return nil, nil
+	}
+
+	// The plugin options end at the closing brace of the options hash (or the end of the line without options).
+	declaration := string(content[loc[1]:])
+	if end := strings.Index(declaration, "}"); end >= 0 {
+		declaration = declaration[:end]
+	}
+
+	match := cocoapodsKeysListRegexp.FindStringSubmatch(declaration)
+	if match == nil {
+		return nil, nil
+	}
+
+	var keys []string
+	for _, quoted := range quotedStringRegexp.FindAllStringSubmatch(match[1], -1) {
+		keys = append(keys, quoted[1])
+	}
+	return keys, nil
+}
+
+// cocoapodsKeysEnvs returns the envs cocoapods-keys reads the key values from (an env with the key's name),
+// so that pod install does not prompt for the keys. The values are read from the mapped (secret) envs.
+// It fails with the list of the Podfile keys which have no value, neither mapped nor set in the environment.
+func (s Step) cocoapodsKeysEnvs(config Config) ([]string, error) {
+	podfileKeys, err := podfileCocoapodsKeys(config.PodfilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cocoapods-keys keys of the Podfile: %w", err)
+	}
+	if len(podfileKeys) == 0 && len(config.CocoapodsKeys) == 0 {
+		return nil, nil
+	}
+
+	s.logger.Printf("")
+	s.logger.Infof("Checking cocoapods-keys keys")
+
+	if len(podfileKeys) == 0 {
+		s.logger.Warnf("The Podfile does not declare %s keys, the mapped keys are passed to pod install anyway", cocoapodsKeysPlugin)
+	}
+
+	var envs []string
+	var missing []string
+	for _, key := range sortedKeys(config.CocoapodsKeys) {
+		secretEnv := config.CocoapodsKeys[key]
+		if value := s.envRepository.Get(secretEnv); value != "" {
+			envs = append(envs, key+"="+value)
+		} else {
+			missing = append(missing, fmt.Sprintf("%s (mapped env %s is empty)", key, secretEnv))
+		}
+	}
+
+	for _, key := range podfileKeys {
+		if _, ok := config.CocoapodsKeys[key]; ok {
+			continue
+		}
+		if s.envRepository.Get(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing %s keys, map them to secret envs with the cocoapods_keys input:\n%s", cocoapodsKeysPlugin, strings.Join(missing, "\n"))
+	}
+
+	for _, key := range sortedKeys(config.CocoapodsKeys) {
+		s.logger.Printf("- %s: set from $%s", key, config.CocoapodsKeys[key])
+	}
+	for _, key := range podfileKeys {
+		if _, ok := config.CocoapodsKeys[key]; !ok {
+			s.logger.Printf("- %s: set in the environment", key)
+		}
+	}
+
+	return envs, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bitrise-steplib/steps-cocoapods-install/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const keysPodfile = `plugin 'cocoapods-binary'
+plugin 'cocoapods-keys', {
+  :project => "App",
+  :keys => [
+    "AnalyticsKey",
+    'MapsAPIKey'
+  ]
+}
+
+target 'App' do
+  pod 'Alamofire', '~> 5.6'
+end
+`
+
+func Test_GivenPodfileWithKeys_WhenReadingKeys_ThenReturnsDeclaredKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		podfile string
+		want    []string
+	}{
+		{name: "hash rocket syntax", podfile: keysPodfile, want: []string{"AnalyticsKey", "MapsAPIKey"}},
+		{name: "keyword syntax", podfile: "plugin 'cocoapods-keys', project: 'App', keys: ['AnalyticsKey']\n", want: []string{"AnalyticsKey"}},
+		{name: "no keys plugin", podfile: "plugin 'cocoapods-binary'\npod 'Alamofire', '~> 5.6'\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := createTestProject(t, map[string]string{"Podfile": tt.podfile})
+
+			keys, err := podfileCocoapodsKeys(filepath.Join(dir, "Podfile"))
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, keys)
+		})
+	}
+}
+
+func Test_GivenMappedSecrets_WhenCollectingKeyEnvs_ThenReturnsEnvsWithoutLoggingValues(t *testing.T) {
+	// Given
+	dir := createTestProject(t, map[string]string{"Podfile": keysPodfile})
+	t.Setenv("ANALYTICS_KEY_SECRET", "analytics-secret-value")
+	t.Setenv("MapsAPIKey", "maps-secret-value")
+
+	var logged []string
+	logger := new(mocks.Logger)
+	for _, method := range []string{"Printf", "Infof", "Warnf", "Donef"} {
+		// The mock flattens the variadic arguments, so every argument count needs its own expectation.
+		for argCount := 1; argCount <= 3; argCount++ {
+			args := make([]interface{}, argCount)
+			for i := range args {
+				args[i] = mock.Anything
+			}
+			logger.On(method, args...).Run(func(args mock.Arguments) {
+				logged = append(logged, fmt.Sprintf(args.String(0), args[1:]...))
+			}).Maybe()
+		}
+	}
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+	step.logger = logger
+
+	// When
+	envs, err := step.cocoapodsKeysEnvs(Config{
+		PodfilePath:   filepath.Join(dir, "Podfile"),
+		CocoapodsKeys: map[string]string{"AnalyticsKey": "ANALYTICS_KEY_SECRET"},
+	})
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{"AnalyticsKey=analytics-secret-value"}, envs)
+	require.NotEmpty(t, logged)
+	for _, line := range logged {
+		require.NotContains(t, line, "secret-value")
+	}
+}
+
+func Test_GivenMissingSecrets_WhenCollectingKeyEnvs_ThenFailsWithMissingKeys(t *testing.T) {
+	// Given
+	dir := createTestProject(t, map[string]string{"Podfile": keysPodfile})
+	t.Setenv("ANALYTICS_KEY_SECRET", "")
+	t.Setenv("MapsAPIKey", "")
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	envs, err := step.cocoapodsKeysEnvs(Config{
+		PodfilePath:   filepath.Join(dir, "Podfile"),
+		CocoapodsKeys: map[string]string{"AnalyticsKey": "ANALYTICS_KEY_SECRET"},
+	})
+
+	// Then
+	require.Nil(t, envs)
+	require.EqualError(t, err, strings.Join([]string{
+		"missing cocoapods-keys keys, map them to secret envs with the cocoapods_keys input:",
+		"AnalyticsKey (mapped env ANALYTICS_KEY_SECRET is empty)",
+		"MapsAPIKey",
+	}, "\n"))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+)
+
+// CocoapodsPlugin is a CocoaPods plugin gem declared in the Podfile.
+type CocoapodsPlugin struct {
+	Gem string
+	// Version is the pinned version of the gem, empty for the latest version.
+	Version string
+}
+
+func (p CocoapodsPlugin) String() string {
+	if p.Version == "" {
+		return p.Gem
+	}
+	return fmt.Sprintf("%s (%s)", p.Gem, p.Version)
+}
+
+// cocoapodsPlugins returns the plugins of the Podfile with the pinned versions, and the pinned gems not used by the Podfile.
+func cocoapodsPlugins(podfileGems []string, pinnedVersions map[string]string) ([]CocoapodsPlugin, []string) {
+	var plugins []CocoapodsPlugin
+	used := map[string]bool{}
+	for _, gem := range podfileGems {
+		if used[gem] {
+			continue
+		}
+		used[gem] = true
+		plugins = append(plugins, CocoapodsPlugin{Gem: gem, Version: pinnedVersions[gem]})
+	}
+
+	var unused []string
+	for gem := range pinnedVersions {
+		if !used[gem] {
+			unused = append(unused, gem)
+		}
+	}
+	sort.Strings(unused)
+
+	return plugins, unused
+}
+
+// isGemInstalledInGemHome tells if the gem (any version if the version is empty) is installed in the gemHome.
+func isGemInstalledInGemHome(gemHome, gem, version string) (bool, error) {
+	if version != "" {
+		if _, err := os.Stat(gemSpecPth(gemHome, gem, version)); err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(gemHome, "specifications", gem+"-*.gemspec"))
+	if err != nil {
+		return false, err
+	}
+	// The glob matches the gems with the same prefix too, for example cocoapods-keys-extra for cocoapods-keys.
+	versionedSpec := regexp.MustCompile(`^` + regexp.QuoteMeta(gem) + `-[0-9][^-]*(-[a-z0-9_]+)?\.gemspec$`)
+	for _, match := range matches {
+		if versionedSpec.MatchString(filepath.Base(match)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// installCocoapodsPlugins installs the plugin gems of the Podfile next to the selected CocoaPods version.
+// With Bundler the plugins are expected to be in the Gemfile, as bundle exec only loads the bundled gems.
+func (s Step) installCocoapodsPlugins(config Config, decision CocoapodsVersionDecision, gemHome string, podEnvs []string) error {
+	podfileGems, err := podfilePlugins(config.PodfilePath)
+	if err != nil {
+		s.logger.Warnf("Failed to read Podfile plugins: %s", err)
+		return nil
+	}
+
+	plugins, unused := cocoapodsPlugins(podfileGems, config.PluginVersions)
+	if len(unused) > 0 {
+		s.logger.Warnf("Pinned plugin versions not used by the Podfile: %s", strings.Join(unused, ", "))
+	}
+	if len(plugins) == 0 {
+		return nil
+	}
+
+	s.logger.Printf("")
+	s.logger.Infof("Checking CocoaPods plugins")
+
+	if decision.Strategy == cocoapodsStrategyBundler {
+		for _, plugin := range plugins {
+			s.logger.Printf("- %s: provided by the Gemfile", plugin.Gem)
+		}
+		return nil
+	}
+
+	for _, plugin := range plugins {
+		var installed bool
+		if gemHome != "" {
+			installed, err = isGemInstalledInGemHome(gemHome, plugin.Gem, plugin.Version)
+		} else {
+			installed, err = s.rubyEnv.IsGemInstalled(plugin.Gem, plugin.Version)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check if plugin %s is installed: %w", plugin, err)
+		}
+
+		if installed {
+			s.logger.Printf("- %s: installed", plugin)
+			continue
+		}
+
+		s.logger.Printf("- %s: installing", plugin)
+
+		var cmds []command.Command
+		if gemHome != "" {
+			args := []string{"install", plugin.Gem, "--no-document"}
+			if plugin.Version != "" {
+				args = append(args, "-v", plugin.Version)
+			}
+			cmds = []command.Command{s.cmdFactory.Create("gem", args, &command.Opts{Env: podEnvs, Dir: config.PodWorkDir})}
+		} else {
+			cmds = s.rubyCmdFactory.CreateGemInstall(plugin.Gem, plugin.Version, false, false, &command.Opts{Env: podEnvs, Dir: config.PodWorkDir})
+		}
+
+		for _, cmd := range cmds {
+			s.logger.Donef("$ %s", cmd.PrintableCommandArgs())
+
+			if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
+				return fmt.Errorf("failed to install plugin %s: %w\noutput: %s", plugin, err, out)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"bitrise-steplib/steps-cocoapods-install/mocks"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const pluginsPodfile = `source 'https://cdn.cocoapods.org/'
+plugin 'cocoapods-binary'
+plugin "cocoapods-keys", {
+  :project => "App",
+  :keys => ["AnalyticsKey"]
+}
+
+target 'App' do
+  pod 'Alamofire'
+end
+`
+
+func Test_GivenPodfilePluginsAndPinnedVersions_WhenCollectingPlugins_ThenReturnsPluginsAndUnusedPins(t *testing.T) {
+	// When
+	plugins, unused := cocoapodsPlugins(
+		[]string{"cocoapods-binary", "cocoapods-keys", "cocoapods-binary"},
+		map[string]string{"cocoapods-keys": "2.3.1", "cocoapods-art": "1.0.0"},
+	)
+
+	// Then
+	require.Equal(t, []CocoapodsPlugin{{Gem: "cocoapods-binary"}, {Gem: "cocoapods-keys", Version: "2.3.1"}}, plugins)
+	require.Equal(t, []string{"cocoapods-art"}, unused)
+	require.Equal(t, "cocoapods-keys (2.3.1)", plugins[1].String())
+}
+
+func Test_GivenGemHome_WhenCheckingPluginInstalled_ThenMatchesGemNameExactly(t *testing.T) {
+	// Given
+	gemHome := createTestProject(t, map[string]string{
+		"specifications/cocoapods-keys-extra-1.0.0.gemspec": "",
+		"specifications/cocoapods-binary-0.4.4.gemspec":     "",
+	})
+
+	// When, Then
+	installed, err := isGemInstalledInGemHome(gemHome, "cocoapods-keys", "")
+	require.NoError(t, err)
+	require.False(t, installed)
+
+	installed, err = isGemInstalledInGemHome(gemHome, "cocoapods-binary", "")
+	require.NoError(t, err)
+	require.True(t, installed)
+
+	installed, err = isGemInstalledInGemHome(gemHome, "cocoapods-binary", "0.4.5")
+	require.NoError(t, err)
+	require.False(t, installed)
+}
+
+func Test_GivenPodfileWithPlugins_WhenInstallingPlugins_ThenInstallsMissingGems(t *testing.T) {
+	// Given
+	dir := createTestProject(t, map[string]string{"Podfile": pluginsPodfile})
+
+	cmdFactory := new(mocks.CommandFactory)
+	gemInstallCmd := new(mocks.Command)
+	gemInstallCmd.On("PrintableCommandArgs").Return("gem install cocoapods-keys -v 2.3.1")
+	gemInstallCmd.On("RunAndReturnTrimmedCombinedOutput").Return("", nil).Once()
+	cmdFactory.On("CreateGemInstall", "cocoapods-keys", "2.3.1", false, false, mock.Anything).Return([]command.Command{gemInstallCmd}).Once()
+
+	rubyEnv := fakeRubyEnvironment{installedGems: map[string]bool{"cocoapods-binary ": true}}
+	step := createTestStep(cmdFactory, rubyEnv, &fakeTracker{})
+
+	// When
+	err := step.installCocoapodsPlugins(Config{
+		PodfilePath:    filepath.Join(dir, "Podfile"),
+		PodfileDir:     dir,
+		PluginVersions: map[string]string{"cocoapods-keys": "2.3.1"},
+	}, CocoapodsVersionDecision{Strategy: cocoapodsStrategyPodfileLock, Version: "1.11.3"}, "", nil)
+
+	// Then
+	require.NoError(t, err)
+	cmdFactory.AssertExpectations(t)
+	gemInstallCmd.AssertExpectations(t)
+}
+
+func Test_GivenBundlerStrategy_WhenInstallingPlugins_ThenLeavesPluginsToTheGemfile(t *testing.T) {
+	// Given
+	dir := createTestProject(t, map[string]string{"Podfile": pluginsPodfile})
+
+	cmdFactory := new(mocks.CommandFactory)
+	step := createTestStep(cmdFactory, fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	err := step.installCocoapodsPlugins(Config{PodfilePath: filepath.Join(dir, "Podfile"), PodfileDir: dir},
+		CocoapodsVersionDecision{Strategy: cocoapodsStrategyBundler}, "", nil)
+
+	// Then
+	require.NoError(t, err)
+	cmdFactory.AssertNotCalled(t, "CreateGemInstall", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/go-steputils/command/gems"
+)
+
+const (
+	cocoapodsStrategyBundler     = "bundler"
+	cocoapodsStrategyPodfileLock = "podfile-lock"
+	cocoapodsStrategySystem      = "system"
+)
+
+const (
+	versionMismatchPolicyPreferGemfile     = "prefer-gemfile"
+	versionMismatchPolicyPreferPodfileLock = "prefer-podfile-lock"
+	versionMismatchPolicyFail              = "fail"
+)
+
+// CocoapodsLockfiles holds the CocoaPods related content of the project's lockfiles.
+type CocoapodsLockfiles struct {
+	// PodfileLockPath is empty if the project has no Podfile.lock.
+	PodfileLockPath    string
+	PodfileLockVersion string
+	// GemfileLockPath is empty if the project has no gem lockfile.
+	GemfileLockPath  string
+	GemfileCocoapods gems.Version
+	GemfileBundler   gems.Version
+}
+
+// CocoapodsVersionDecision describes how the CocoaPods version to run is provided.
+type CocoapodsVersionDecision struct {
+	Strategy string
+	// Version is the CocoaPods version (or version requirement in case of Bundler), empty for the system CocoaPods.
+	Version  string
+	Reason   string
+	Warnings []string
+	// PodCmdPrefix is the command which invokes the selected CocoaPods version, for example: [bundle _2.4.10_ exec pod].
+	PodCmdPrefix []string
+	// Installed tells if the gem providing the selected version (bundler or cocoapods) is already installed, set by ResolveInstalled.
+	Installed bool
+
+	BundlerVersion  gems.Version
+	GemfileLockPath string
+}
+
+// InstalledGems tells if the gems providing CocoaPods are installed in the selected Ruby version.
+type InstalledGems struct {
+	Cocoapods bool
+	Bundler   bool
+}
+
+// VersionResolver decides which CocoaPods version to use based on the project's lockfiles (Resolve),
+// and whether it needs to be installed based on the installed gems (ResolveInstalled).
+// The installed gems depend on the Ruby version, so they are passed in after the Ruby version is selected.
+type VersionResolver struct{}
+
+// NewVersionResolver ...
+func NewVersionResolver() VersionResolver {
+	return VersionResolver{}
+}
+
+// Resolve prefers the CocoaPods version locked by Bundler, then the version from Podfile.lock, and falls back to the system CocoaPods.
+// The mismatch policy decides what happens if the Podfile.lock version does not match the gem lockfile version.
+func (r VersionResolver) Resolve(lockfiles CocoapodsLockfiles, mismatchPolicy string) (CocoapodsVersionDecision, error) {
+	var warnings []string
+	if lockfiles.PodfileLockPath != "" && lockfiles.PodfileLockVersion == "" {
+		warnings = append(warnings, fmt.Sprintf("No CocoaPods version found in Podfile.lock! (%s)", lockfiles.PodfileLockPath))
+	}
+
+	if lockfiles.GemfileCocoapods.Found {
+		if lockfiles.PodfileLockVersion != "" {
+			matches, err := isIncludedInGemfileLockVersionRanges(lockfiles.PodfileLockVersion, lockfiles.GemfileCocoapods.Version)
+			if err != nil {
+				return CocoapodsVersionDecision{}, fmt.Errorf("failed to compare version range in gem lockfile: %w", err)
+			}
+			if !matches {
+				switch mismatchPolicy {
+				case versionMismatchPolicyFail:
+					return CocoapodsVersionDecision{}, versionMismatchError(lockfiles)
+				case versionMismatchPolicyPreferPodfileLock:
+					warnings = append(warnings, fmt.Sprintf("Cocoapods version required in Podfile.lock (%s) does not match Gemfile.lock (%s). Will install Cocoapods version from Podfile.lock.", lockfiles.PodfileLockVersion, lockfiles.GemfileCocoapods.Version))
+					reason := fmt.Sprintf("CocoaPods version found in Podfile.lock (%s) and version_mismatch_policy is %s", lockfiles.PodfileLockPath, versionMismatchPolicyPreferPodfileLock)
+					return r.podfileLockDecision(lockfiles, reason, warnings), nil
+				default:
+					warnings = append(warnings, fmt.Sprintf("Cocoapods version required in Podfile.lock (%s) does not match Gemfile.lock (%s). Will install Cocoapods using bundler.", lockfiles.PodfileLockVersion, lockfiles.GemfileCocoapods.Version))
+				}
+			}
+		}
+
+		return CocoapodsVersionDecision{
+			Strategy:        cocoapodsStrategyBundler,
+			Version:         lockfiles.GemfileCocoapods.Version,
+			Reason:          fmt.Sprintf("cocoapods gem found in gem lockfile (%s)", lockfiles.GemfileLockPath),
+			Warnings:        warnings,
+			PodCmdPrefix:    append(gems.BundleExecPrefix(lockfiles.GemfileBundler), "pod"),
+			BundlerVersion:  lockfiles.GemfileBundler,
+			GemfileLockPath: lockfiles.GemfileLockPath,
+		}, nil
+	}
+
+	if lockfiles.PodfileLockVersion != "" {
+		reason := fmt.Sprintf("CocoaPods version found in Podfile.lock (%s) and no cocoapods gem found in gem lockfile", lockfiles.PodfileLockPath)
+		return r.podfileLockDecision(lockfiles, reason, warnings), nil
+	}
+
+	return CocoapodsVersionDecision{
+		Strategy:     cocoapodsStrategySystem,
+		Reason:       "no CocoaPods version found in Podfile.lock or gem lockfile",
+		Warnings:     warnings,
+		PodCmdPrefix: []string{"pod"},
+	}, nil
+}
+
+// ResolveInstalled sets if the gem providing the decided CocoaPods version is installed: bundler for the Bundler strategy,
+// the cocoapods gem for the Podfile.lock strategy. The system CocoaPods is never installed by the Step.
+func (r VersionResolver) ResolveInstalled(decision CocoapodsVersionDecision, installedGems InstalledGems) CocoapodsVersionDecision {
+	switch decision.Strategy {
+	case cocoapodsStrategyBundler:
+		decision.Installed = installedGems.Bundler
+	case cocoapodsStrategyPodfileLock:
+		decision.Installed = installedGems.Cocoapods
+	default:
+		decision.Installed = true
+	}
+	return decision
+}
+
+func (r VersionResolver) podfileLockDecision(lockfiles CocoapodsLockfiles, reason string, warnings []string) CocoapodsVersionDecision {
+	return CocoapodsVersionDecision{
+		Strategy:     cocoapodsStrategyPodfileLock,
+		Version:      lockfiles.PodfileLockVersion,
+		Reason:       reason,
+		Warnings:     warnings,
+		PodCmdPrefix: []string{"pod", fmt.Sprintf("_%s_", lockfiles.PodfileLockVersion)},
+	}
+}
+
+func versionMismatchError(lockfiles CocoapodsLockfiles) error {
+	return withErrorCategory(errorCategoryVersionMismatch, fmt.Errorf(`CocoaPods version required in Podfile.lock (%s) does not match the version in the gem lockfile (%s)
+Podfile.lock: %s
+Gem lockfile: %s
+To use CocoaPods %s: require it in the Gemfile, run `+"`bundle update cocoapods`"+` and commit the updated gem lockfile.
+To use CocoaPods %s: run `+"`bundle exec pod install`"+` and commit the updated Podfile.lock.
+Set the version_mismatch_policy input to %s or %s to continue despite the mismatch.`,
+		lockfiles.PodfileLockVersion, lockfiles.GemfileCocoapods.Version,
+		lockfiles.PodfileLockPath, lockfiles.GemfileLockPath,
+		lockfiles.PodfileLockVersion,
+		lockfiles.GemfileCocoapods.Version,
+		versionMismatchPolicyPreferGemfile, versionMismatchPolicyPreferPodfileLock))
+}
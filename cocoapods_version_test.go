@@ -0,0 +1,242 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-steputils/command/gems"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenLockfiles_WhenResolvingCocoapodsVersion_ThenReturnsDecision(t *testing.T) {
+	const (
+		podfileLockPath = "/project/Podfile.lock"
+		gemfileLockPath = "/project/Gemfile.lock"
+	)
+	bundler := gems.Version{Version: "2.4.10", Found: true}
+
+	tests := []struct {
+		name      string
+		lockfiles CocoapodsLockfiles
+		policy    string
+		want      CocoapodsVersionDecision
+		wantErr   string
+	}{
+		{
+			name:      "no lockfiles",
+			lockfiles: CocoapodsLockfiles{},
+			want: CocoapodsVersionDecision{
+				Strategy:     cocoapodsStrategySystem,
+				Reason:       "no CocoaPods version found in Podfile.lock or gem lockfile",
+				PodCmdPrefix: []string{"pod"},
+			},
+		},
+		{
+			name:      "Podfile.lock without CocoaPods version",
+			lockfiles: CocoapodsLockfiles{PodfileLockPath: podfileLockPath},
+			want: CocoapodsVersionDecision{
+				Strategy:     cocoapodsStrategySystem,
+				Reason:       "no CocoaPods version found in Podfile.lock or gem lockfile",
+				Warnings:     []string{"No CocoaPods version found in Podfile.lock! (/project/Podfile.lock)"},
+				PodCmdPrefix: []string{"pod"},
+			},
+		},
+		{
+			name:      "Podfile.lock version",
+			lockfiles: CocoapodsLockfiles{PodfileLockPath: podfileLockPath, PodfileLockVersion: "1.11.3"},
+			want: CocoapodsVersionDecision{
+				Strategy:     cocoapodsStrategyPodfileLock,
+				Version:      "1.11.3",
+				Reason:       "CocoaPods version found in Podfile.lock (/project/Podfile.lock) and no cocoapods gem found in gem lockfile",
+				PodCmdPrefix: []string{"pod", "_1.11.3_"},
+			},
+		},
+		{
+			name: "gem lockfile without cocoapods gem",
+			lockfiles: CocoapodsLockfiles{
+				PodfileLockPath:    podfileLockPath,
+				PodfileLockVersion: "1.11.3",
+				GemfileLockPath:    gemfileLockPath,
+				GemfileBundler:     bundler,
+			},
+			want: CocoapodsVersionDecision{
+				Strategy:     cocoapodsStrategyPodfileLock,
+				Version:      "1.11.3",
+				Reason:       "CocoaPods version found in Podfile.lock (/project/Podfile.lock) and no cocoapods gem found in gem lockfile",
+				PodCmdPrefix: []string{"pod", "_1.11.3_"},
+			},
+		},
+		{
+			name: "gem lockfile without Podfile.lock",
+			lockfiles: CocoapodsLockfiles{
+				GemfileLockPath:  gemfileLockPath,
+				GemfileCocoapods: gems.Version{Version: "1.11.3", Found: true},
+				GemfileBundler:   bundler,
+			},
+			want: CocoapodsVersionDecision{
+				Strategy:        cocoapodsStrategyBundler,
+				Version:         "1.11.3",
+				Reason:          "cocoapods gem found in gem lockfile (/project/Gemfile.lock)",
+				PodCmdPrefix:    []string{"bundle", "_2.4.10_", "exec", "pod"},
+				BundlerVersion:  bundler,
+				GemfileLockPath: gemfileLockPath,
+			},
+		},
+		{
+			name: "lockfiles agree",
+			lockfiles: CocoapodsLockfiles{
+				PodfileLockPath:    podfileLockPath,
+				PodfileLockVersion: "1.11.3",
+				GemfileLockPath:    gemfileLockPath,
+				GemfileCocoapods:   gems.Version{Version: "1.11.3", Found: true},
+				GemfileBundler:     bundler,
+			},
+			want: CocoapodsVersionDecision{
+				Strategy:        cocoapodsStrategyBundler,
+				Version:         "1.11.3",
+				Reason:          "cocoapods gem found in gem lockfile (/project/Gemfile.lock)",
+				PodCmdPrefix:    []string{"bundle", "_2.4.10_", "exec", "pod"},
+				BundlerVersion:  bundler,
+				GemfileLockPath: gemfileLockPath,
+			},
+		},
+		{
+			name: "lockfiles disagree",
+			lockfiles: CocoapodsLockfiles{
+				PodfileLockPath:    podfileLockPath,
+				PodfileLockVersion: "1.10.0",
+				GemfileLockPath:    gemfileLockPath,
+				GemfileCocoapods:   gems.Version{Version: "1.11.3", Found: true},
+				GemfileBundler:     bundler,
+			},
+			policy: versionMismatchPolicyPreferGemfile,
+			want: CocoapodsVersionDecision{
+				Strategy:        cocoapodsStrategyBundler,
+				Version:         "1.11.3",
+				Reason:          "cocoapods gem found in gem lockfile (/project/Gemfile.lock)",
+				Warnings:        []string{"Cocoapods version required in Podfile.lock (1.10.0) does not match Gemfile.lock (1.11.3). Will install Cocoapods using bundler."},
+				PodCmdPrefix:    []string{"bundle", "_2.4.10_", "exec", "pod"},
+				BundlerVersion:  bundler,
+				GemfileLockPath: gemfileLockPath,
+			},
+		},
+		{
+			name: "lockfiles disagree with prefer-podfile-lock policy",
+			lockfiles: CocoapodsLockfiles{
+				PodfileLockPath:    podfileLockPath,
+				PodfileLockVersion: "1.10.0",
+				GemfileLockPath:    gemfileLockPath,
+				GemfileCocoapods:   gems.Version{Version: "1.11.3", Found: true},
+				GemfileBundler:     bundler,
+			},
+			policy: versionMismatchPolicyPreferPodfileLock,
+			want: CocoapodsVersionDecision{
+				Strategy:     cocoapodsStrategyPodfileLock,
+				Version:      "1.10.0",
+				Reason:       "CocoaPods version found in Podfile.lock (/project/Podfile.lock) and version_mismatch_policy is prefer-podfile-lock",
+				Warnings:     []string{"Cocoapods version required in Podfile.lock (1.10.0) does not match Gemfile.lock (1.11.3). Will install Cocoapods version from Podfile.lock."},
+				PodCmdPrefix: []string{"pod", "_1.10.0_"},
+			},
+		},
+		{
+			name: "lockfiles disagree with fail policy",
+			lockfiles: CocoapodsLockfiles{
+				PodfileLockPath:    podfileLockPath,
+				PodfileLockVersion: "1.10.0",
+				GemfileLockPath:    gemfileLockPath,
+				GemfileCocoapods:   gems.Version{Version: "1.11.3", Found: true},
+				GemfileBundler:     bundler,
+			},
+			policy: versionMismatchPolicyFail,
+			wantErr: `CocoaPods version required in Podfile.lock (1.10.0) does not match the version in the gem lockfile (1.11.3)
+Podfile.lock: /project/Podfile.lock
+Gem lockfile: /project/Gemfile.lock
+To use CocoaPods 1.10.0: require it in the Gemfile, run ` + "`bundle update cocoapods`" + ` and commit the updated gem lockfile.
+To use CocoaPods 1.11.3: run ` + "`bundle exec pod install`" + ` and commit the updated Podfile.lock.
+Set the version_mismatch_policy input to prefer-gemfile or prefer-podfile-lock to continue despite the mismatch.`,
+		},
+		{
+			name: "lockfiles agree with fail policy",
+			lockfiles: CocoapodsLockfiles{
+				PodfileLockPath:    podfileLockPath,
+				PodfileLockVersion: "1.11.3",
+				GemfileLockPath:    gemfileLockPath,
+				GemfileCocoapods:   gems.Version{Version: "1.11.3", Found: true},
+				GemfileBundler:     bundler,
+			},
+			policy: versionMismatchPolicyFail,
+			want: CocoapodsVersionDecision{
+				Strategy:        cocoapodsStrategyBundler,
+				Version:         "1.11.3",
+				Reason:          "cocoapods gem found in gem lockfile (/project/Gemfile.lock)",
+				PodCmdPrefix:    []string{"bundle", "_2.4.10_", "exec", "pod"},
+				BundlerVersion:  bundler,
+				GemfileLockPath: gemfileLockPath,
+			},
+		},
+		{
+			name: "gem lockfile without bundler version",
+			lockfiles: CocoapodsLockfiles{
+				GemfileLockPath:  gemfileLockPath,
+				GemfileCocoapods: gems.Version{Version: "1.11.3", Found: true},
+			},
+			want: CocoapodsVersionDecision{
+				Strategy:        cocoapodsStrategyBundler,
+				Version:         "1.11.3",
+				Reason:          "cocoapods gem found in gem lockfile (/project/Gemfile.lock)",
+				PodCmdPrefix:    []string{"bundle", "exec", "pod"},
+				GemfileLockPath: gemfileLockPath,
+			},
+		},
+		{
+			name: "invalid gem lockfile version range",
+			lockfiles: CocoapodsLockfiles{
+				PodfileLockPath:    podfileLockPath,
+				PodfileLockVersion: "1.11.3",
+				GemfileLockPath:    gemfileLockPath,
+				GemfileCocoapods:   gems.Version{Version: "!= 1.11.3", Found: true},
+				GemfileBundler:     bundler,
+			},
+			wantErr: "failed to compare version range in gem lockfile: Unknown version operator: != 1.11.3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewVersionResolver()
+
+			decision, err := resolver.Resolve(tt.lockfiles, tt.policy)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, decision)
+		})
+	}
+}
+
+func Test_GivenInstalledGems_WhenResolvingInstalled_ThenChecksTheGemOfTheStrategy(t *testing.T) {
+	tests := []struct {
+		name          string
+		strategy      string
+		installedGems InstalledGems
+		want          bool
+	}{
+		{name: "bundler installed", strategy: cocoapodsStrategyBundler, installedGems: InstalledGems{Bundler: true}, want: true},
+		{name: "only cocoapods installed for bundler", strategy: cocoapodsStrategyBundler, installedGems: InstalledGems{Cocoapods: true}, want: false},
+		{name: "cocoapods installed", strategy: cocoapodsStrategyPodfileLock, installedGems: InstalledGems{Cocoapods: true}, want: true},
+		{name: "only bundler installed for Podfile.lock", strategy: cocoapodsStrategyPodfileLock, installedGems: InstalledGems{Bundler: true}, want: false},
+		{name: "system cocoapods", strategy: cocoapodsStrategySystem, installedGems: InstalledGems{}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := CocoapodsVersionDecision{Strategy: tt.strategy, Version: "1.11.3"}
+
+			got := NewVersionResolver().ResolveInstalled(decision, tt.installedGems)
+
+			require.Equal(t, tt.want, got.Installed)
+			require.Equal(t, tt.strategy, got.Strategy)
+			require.Equal(t, "1.11.3", got.Version)
+		})
+	}
+}
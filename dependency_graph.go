@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DependencyGraph is the pod dependency graph of a Podfile.lock.
+type DependencyGraph struct {
+	// PodfileDependencies are the pods required by the Podfile (DEPENDENCIES section of Podfile.lock).
+	PodfileDependencies []string             `json:"podfile_dependencies"`
+	Pods                []DependencyGraphPod `json:"pods"`
+
+	podsByName map[string]int
+}
+
+// DependencyGraphPod is a node of the dependency graph, a pod or a subspec.
+type DependencyGraphPod struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// RootPod is the name of the pod without the subspec, equals to Name for root pods.
+	RootPod string `json:"root_pod"`
+	Subspec bool   `json:"subspec"`
+	Source  string `json:"source,omitempty"`
+	// Dependencies are the direct dependencies of the pod.
+	Dependencies []string `json:"dependencies"`
+	// Dependents are the pods directly depending on the pod.
+	Dependents []string `json:"dependents"`
+	// PulledInBy are the Podfile dependencies the pod is (transitively) required by.
+	PulledInBy []string `json:"pulled_in_by"`
+}
+
+// NewDependencyGraph builds the dependency graph of the locked pods.
+// Dependencies which are not locked as a pod on their own are resolved to the locked subspecs of the pod.
+func NewDependencyGraph(lock PodfileLock) DependencyGraph {
+	graph := DependencyGraph{podsByName: map[string]int{}}
+
+	for _, pod := range lock.Pods {
+		root := podRootName(pod.Name)
+		graph.podsByName[pod.Name] = len(graph.Pods)
+		graph.Pods = append(graph.Pods, DependencyGraphPod{
+			Name:         pod.Name,
+			Version:      pod.Version,
+			RootPod:      root,
+			Subspec:      root != pod.Name,
+			Source:       lock.PodSourceType(root),
+			Dependencies: []string{},
+			Dependents:   []string{},
+			PulledInBy:   []string{},
+		})
+	}
+
+	for _, pod := range lock.Pods {
+		from := graph.podsByName[pod.Name]
+		for _, dependency := range pod.Dependencies {
+			for _, name := range graph.resolve(dependency.Name) {
+				graph.Pods[from].Dependencies = appendUnique(graph.Pods[from].Dependencies, name)
+				to := graph.podsByName[name]
+				graph.Pods[to].Dependents = appendUnique(graph.Pods[to].Dependents, pod.Name)
+			}
+		}
+	}
+
+	for _, dependency := range lock.Dependencies {
+		graph.PodfileDependencies = appendUnique(graph.PodfileDependencies, dependency.Name)
+	}
+	sort.Strings(graph.PodfileDependencies)
+
+	for _, dependency := range graph.PodfileDependencies {
+		for _, name := range graph.reachable(dependency) {
+			idx := graph.podsByName[name]
+			graph.Pods[idx].PulledInBy = appendUnique(graph.Pods[idx].PulledInBy, dependency)
+		}
+	}
+
+	return graph
+}
+
+// resolve returns the locked pods matching the dependency name:
+// the pod itself if locked, otherwise its locked subspecs.
+func (g DependencyGraph) resolve(name string) []string {
+	if _, ok := g.podsByName[name]; ok {
+		return []string{name}
+	}
+
+	var subspecs []string
+	for _, pod := range g.Pods {
+		if strings.HasPrefix(pod.Name, name+"/") {
+			subspecs = append(subspecs, pod.Name)
+		}
+	}
+	return subspecs
+}
+
+// reachable returns the pods required by the given Podfile dependency, including the dependency itself.
+func (g DependencyGraph) reachable(dependency string) []string {
+	visited := map[string]bool{}
+	queue := g.resolve(dependency)
+	var pods []string
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if visited[name] {
+			continue
+		}
+		visited[name] = true
+		pods = append(pods, name)
+		queue = append(queue, g.Pods[g.podsByName[name]].Dependencies...)
+	}
+	return pods
+}
+
+// Why returns the shortest dependency chain from each Podfile dependency to the given pod.
+// The pod can be given with or without subspec, a root pod name matches all of its locked subspecs.
+func (g DependencyGraph) Why(name string) [][]string {
+	targets := map[string]bool{}
+	for _, pod := range g.Pods {
+		if pod.Name == name || pod.RootPod == name {
+			targets[pod.Name] = true
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	var chains [][]string
+	for _, dependency := range g.PodfileDependencies {
+		if chain := g.shortestChain(dependency, targets); chain != nil {
+			chains = append(chains, chain)
+		}
+	}
+	return chains
+}
+
+func (g DependencyGraph) shortestChain(dependency string, targets map[string]bool) []string {
+	parents := map[string]string{}
+	visited := map[string]bool{}
+	var queue []string
+	for _, name := range g.resolve(dependency) {
+		visited[name] = true
+		queue = append(queue, name)
+	}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		if targets[name] {
+			chain := []string{name}
+			for parent, ok := parents[name]; ok; parent, ok = parents[parent] {
+				chain = append([]string{parent}, chain...)
+			}
+			if chain[0] != dependency {
+				chain = append([]string{dependency}, chain...)
+			}
+			return chain
+		}
+
+		for _, next := range g.Pods[g.podsByName[name]].Dependencies {
+			if !visited[next] {
+				visited[next] = true
+				parents[next] = name
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return nil
+}
+
+// JSON returns the indented JSON representation of the graph.
+func (g DependencyGraph) JSON() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
+
+// DOT returns the Graphviz DOT representation of the graph.
+// The Podfile dependencies are connected to a Podfile node, the subspecs are grouped by their root pod.
+func (g DependencyGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph Pods {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	b.WriteString("  \"Podfile\" [shape=folder];\n")
+
+	var rootPods []string
+	subspecs := map[string][]DependencyGraphPod{}
+	for _, pod := range g.Pods {
+		if !pod.Subspec {
+			fmt.Fprintf(&b, "  %q [label=%q];\n", pod.Name, pod.Name+"\n"+pod.Version)
+			continue
+		}
+		if _, ok := subspecs[pod.RootPod]; !ok {
+			rootPods = append(rootPods, pod.RootPod)
+		}
+		subspecs[pod.RootPod] = append(subspecs[pod.RootPod], pod)
+	}
+
+	for _, root := range rootPods {
+		fmt.Fprintf(&b, "  subgraph %q {\n", "cluster_"+root)
+		fmt.Fprintf(&b, "    label=%q;\n", root)
+		for _, pod := range subspecs[root] {
+			fmt.Fprintf(&b, "    %q [label=%q];\n", pod.Name, pod.Name+"\n"+pod.Version)
+		}
+		b.WriteString("  }\n")
+	}
+
+	for _, dependency := range g.PodfileDependencies {
+		for _, name := range g.resolve(dependency) {
+			fmt.Fprintf(&b, "  \"Podfile\" -> %q;\n", name)
+		}
+	}
+	for _, pod := range g.Pods {
+		for _, dependency := range pod.Dependencies {
+			fmt.Fprintf(&b, "  %q -> %q;\n", pod.Name, dependency)
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func appendUnique(items []string, item string) []string {
+	for _, existing := range items {
+		if existing == item {
+			return items
+		}
+	}
+	return append(items, item)
+}
+
+// reportDependencyGraph prints why the pods of the why input are installed
+// and writes the dependency graph artifacts if the dependency_graph input is enabled.
+func (s Step) reportDependencyGraph(config Config, lock PodfileLock, result *Result) error {
+	graph := NewDependencyGraph(lock)
+
+	for _, pod := range config.WhyPods {
+		s.logger.Printf("")
+		s.logger.Infof("Why is %s installed?", pod)
+
+		chains := graph.Why(pod)
+		if len(chains) == 0 {
+			s.logger.Printf("%s is not installed", pod)
+			continue
+		}
+		for _, chain := range chains {
+			s.logger.Printf("- %s", strings.Join(chain, " -> "))
+		}
+	}
+
+	if !config.DependencyGraph {
+		return nil
+	}
+
+	s.logger.Printf("")
+	s.logger.Infof("Exporting dependency graph")
+
+	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	content, err := graph.JSON()
+	if err != nil {
+		return fmt.Errorf("failed to encode dependency graph: %w", err)
+	}
+	jsonPath := filepath.Join(config.OutputDir, "pods_dependency_graph.json")
+	if err := os.WriteFile(jsonPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write dependency graph: %w", err)
+	}
+
+	dotPath := filepath.Join(config.OutputDir, "pods_dependency_graph.dot")
+	if err := os.WriteFile(dotPath, []byte(graph.DOT()), 0644); err != nil {
+		return fmt.Errorf("failed to write dependency graph: %w", err)
+	}
+
+	s.logger.Donef("Dependency graph of %d pods: %s, %s", len(graph.Pods), jsonPath, dotPath)
+	result.DependencyGraphJSONPath = jsonPath
+	result.DependencyGraphDOTPath = dotPath
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenPodfileLock_WhenBuildingDependencyGraph_ThenLinksPods(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(podfileLockWithSourcesContent))
+	require.NoError(t, err)
+
+	// When
+	graph := NewDependencyGraph(lock)
+
+	// Then
+	require.Equal(t, []string{"Alamofire", "Firebase/Core", "InternalKit", "LocalKit", "SwiftyJSON"}, graph.PodfileDependencies)
+	require.Equal(t, DependencyGraphPod{
+		Name:         "Firebase/CoreOnly",
+		Version:      "10.0.0",
+		RootPod:      "Firebase",
+		Subspec:      true,
+		Source:       podSourceTrunk,
+		Dependencies: []string{"FirebaseCore"},
+		Dependents:   []string{"Firebase/Core"},
+		PulledInBy:   []string{"Firebase/Core"},
+	}, graph.Pods[2])
+	require.Equal(t, DependencyGraphPod{
+		Name:         "SwiftyJSON",
+		Version:      "5.0.1",
+		RootPod:      "SwiftyJSON",
+		Source:       podSourceGit,
+		Dependencies: []string{},
+		Dependents:   []string{},
+		PulledInBy:   []string{"SwiftyJSON"},
+	}, graph.Pods[7])
+
+	content, err := graph.JSON()
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	require.Len(t, decoded["pods"], 8)
+}
+
+func Test_GivenDependencyGraph_WhenAskingWhy_ThenReturnsChainsFromPodfileDependencies(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(`PODS:
+  - A (1.0.0):
+    - C (~> 1.0)
+  - B (1.0.0):
+    - D
+  - C (1.0.0):
+    - E
+  - D (1.0.0):
+    - E
+  - E (1.0.0)
+  - Firebase/Core (10.0.0)
+
+DEPENDENCIES:
+  - A
+  - B
+  - Firebase
+`))
+	require.NoError(t, err)
+	graph := NewDependencyGraph(lock)
+
+	tests := []struct {
+		name string
+		pod  string
+		want [][]string
+	}{
+		{name: "transitive dependency", pod: "E", want: [][]string{{"A", "C", "E"}, {"B", "D", "E"}}},
+		{name: "Podfile dependency", pod: "A", want: [][]string{{"A"}}},
+		{name: "subspec of a Podfile dependency", pod: "Firebase/Core", want: [][]string{{"Firebase", "Firebase/Core"}}},
+		{name: "root of a subspec", pod: "Firebase", want: [][]string{{"Firebase", "Firebase/Core"}}},
+		{name: "not installed", pod: "Alamofire", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, graph.Why(tt.pod))
+		})
+	}
+}
+
+func Test_GivenDependencyGraph_WhenExportingDOT_ThenGroupsSubspecs(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(`PODS:
+  - Alamofire (5.6.4)
+  - Firebase/Core (10.0.0):
+    - Alamofire
+
+DEPENDENCIES:
+  - Firebase/Core
+`))
+	require.NoError(t, err)
+
+	// When
+	dot := NewDependencyGraph(lock).DOT()
+
+	// Then
+	require.Equal(t, `digraph Pods {
+  rankdir=LR;
+  node [shape=box];
+  "Podfile" [shape=folder];
+  "Alamofire" [label="Alamofire\n5.6.4"];
+  subgraph "cluster_Firebase" {
+    label="Firebase";
+    "Firebase/Core" [label="Firebase/Core\n10.0.0"];
+  }
+  "Podfile" -> "Firebase/Core";
+  "Firebase/Core" -> "Alamofire";
+}
+`, dot)
+}
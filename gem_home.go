@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// isolatedGemHomeDir returns the Step owned GEM_HOME for the given Ruby and CocoaPods versions,
+// a separate directory for every version pair, so that the directory can be cached as is.
+func isolatedGemHomeDir(homeDir, rubyVersion, cocoapodsVersion string) string {
+	return filepath.Join(homeDir, ".bitrise", "cocoapods-install", "gems", "ruby-"+rubyVersion, "cocoapods-"+cocoapodsVersion)
+}
+
+// gemHomeEnvs returns the environment which makes gem install into, and the executables run from the gemHome.
+// Gems installed into the default gem directories remain available, as GEM_PATH is left untouched.
+func gemHomeEnvs(gemHome, path string) []string {
+	binDir := filepath.Join(gemHome, "bin")
+	if path != "" {
+		binDir += string(os.PathListSeparator) + path
+	}
+	return []string{"GEM_HOME=" + gemHome, "PATH=" + binDir}
+}
+
+// gemSpecPth returns the path of the installed gem's specification in the gemHome.
+func gemSpecPth(gemHome, gem, version string) string {
+	return filepath.Join(gemHome, "specifications", fmt.Sprintf("%s-%s.gemspec", gem, version))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/command/gems"
+)
+
+// gemfileLockNames maps the supported Gemfile names to their lockfile names.
+var gemfileLockNames = map[string]string{
+	"Gemfile": "Gemfile.lock",
+	"gems.rb": "gems.locked",
+}
+
+// gemfileLockPthForGemfile returns the lockfile path belonging to the given Gemfile.
+func gemfileLockPthForGemfile(gemfilePth string) (string, error) {
+	lockName, ok := gemfileLockNames[filepath.Base(gemfilePth)]
+	if !ok {
+		return "", fmt.Errorf("unsupported Gemfile name: %s, should be one of: Gemfile, gems.rb", filepath.Base(gemfilePth))
+	}
+	return filepath.Join(filepath.Dir(gemfilePth), lockName), nil
+}
+
+// gemfilePthForGemfileLock returns the Gemfile path belonging to the given gem lockfile.
+func gemfilePthForGemfileLock(gemfileLockPth string) string {
+	lockName := filepath.Base(gemfileLockPth)
+	for gemfileName, name := range gemfileLockNames {
+		if name == lockName {
+			return filepath.Join(filepath.Dir(gemfileLockPth), gemfileName)
+		}
+	}
+	return strings.TrimSuffix(gemfileLockPth, ".lock")
+}
+
+// findGemfileLock searches for a gem lockfile in the Podfile's directory and in its parent directories
+// up to (and including) the root directory.
+// If the Podfile's directory is not inside the root directory, only the Podfile's directory is searched.
+func findGemfileLock(podfileDir, rootDir string) (string, error) {
+	for _, dir := range gemfileLockSearchDirs(podfileDir, rootDir) {
+		pth, err := gems.GemFileLockPth(dir)
+		if err == gems.ErrGemLockNotFound {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return pth, nil
+	}
+	return "", gems.ErrGemLockNotFound
+}
+
+func gemfileLockSearchDirs(podfileDir, rootDir string) []string {
+	podfileDir = filepath.Clean(podfileDir)
+	rootDir = filepath.Clean(rootDir)
+
+	rel, err := filepath.Rel(rootDir, podfileDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return []string{podfileDir}
+	}
+
+	dirs := []string{podfileDir}
+	for dir := podfileDir; dir != rootDir; {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
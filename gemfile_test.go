@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-steputils/command/gems"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindGemfileLock(t *testing.T) {
+	tests := []struct {
+		name       string
+		lockfiles  []string
+		podfileDir string
+		rootDir    string
+		want       string
+		wantErr    error
+	}{
+		{
+			name:       "Gemfile.lock next to the Podfile",
+			lockfiles:  []string{"ios/Gemfile.lock", "Gemfile.lock"},
+			podfileDir: "ios",
+			rootDir:    "",
+			want:       "ios/Gemfile.lock",
+		},
+		{
+			name:       "Gemfile.lock in the root directory",
+			lockfiles:  []string{"Gemfile.lock"},
+			podfileDir: "ios",
+			rootDir:    "",
+			want:       "Gemfile.lock",
+		},
+		{
+			name:       "gems.locked in a parent directory",
+			lockfiles:  []string{"apps/gems.locked"},
+			podfileDir: "apps/mobile/ios",
+			rootDir:    "",
+			want:       "apps/gems.locked",
+		},
+		{
+			name:       "Gemfile.lock above the root directory is ignored",
+			lockfiles:  []string{"Gemfile.lock"},
+			podfileDir: "apps/ios",
+			rootDir:    "apps",
+			wantErr:    gems.ErrGemLockNotFound,
+		},
+		{
+			name:       "Podfile outside of the root directory",
+			lockfiles:  []string{"Gemfile.lock"},
+			podfileDir: "ios",
+			rootDir:    "android",
+			wantErr:    gems.ErrGemLockNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for _, lockfile := range tt.lockfiles {
+				pth := filepath.Join(tmpDir, lockfile)
+				require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+				require.NoError(t, os.WriteFile(pth, []byte(""), 0644))
+			}
+			require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, tt.podfileDir), 0755))
+
+			got, err := findGemfileLock(filepath.Join(tmpDir, tt.podfileDir), filepath.Join(tmpDir, tt.rootDir))
+			if tt.wantErr != nil {
+				require.Equal(t, tt.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, filepath.Join(tmpDir, tt.want), got)
+		})
+	}
+}
+
+func TestGemfileLockPthForGemfile(t *testing.T) {
+	pth, err := gemfileLockPthForGemfile("/project/Gemfile")
+	require.NoError(t, err)
+	require.Equal(t, "/project/Gemfile.lock", pth)
+
+	pth, err = gemfileLockPthForGemfile("/project/gems.rb")
+	require.NoError(t, err)
+	require.Equal(t, "/project/gems.locked", pth)
+
+	_, err = gemfileLockPthForGemfile("/project/Podfile")
+	require.Error(t, err)
+}
+
+func TestGemfilePthForGemfileLock(t *testing.T) {
+	require.Equal(t, "/project/Gemfile", gemfilePthForGemfileLock("/project/Gemfile.lock"))
+	require.Equal(t, "/project/gems.rb", gemfilePthForGemfileLock("/project/gems.locked"))
+}
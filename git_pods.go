@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GitPod is a pod installed from a git repository (a :git external source).
+type GitPod struct {
+	Name       string
+	Repository string
+	// Branch, Tag and Commit are the references requested in the Podfile, all empty for the default branch.
+	Branch string
+	Tag    string
+	Commit string
+	// LockedCommit is the commit recorded in the CHECKOUT OPTIONS of Podfile.lock.
+	LockedCommit string
+}
+
+// IsTrackingBranch tells if the pod follows a branch (or the default branch), instead of a tag or a commit.
+func (p GitPod) IsTrackingBranch() bool {
+	return p.Tag == "" && p.Commit == ""
+}
+
+// LockedReference returns the locked commit, or the tag if CocoaPods recorded only the tag of the checkout.
+func (p GitPod) LockedReference() string {
+	if p.LockedCommit != "" {
+		return p.LockedCommit
+	}
+	return p.Tag
+}
+
+func (p GitPod) String() string {
+	requested := "default branch"
+	switch {
+	case p.Commit != "":
+		requested = "commit " + p.Commit
+	case p.Tag != "":
+		requested = "tag " + p.Tag
+	case p.Branch != "":
+		requested = "branch " + p.Branch
+	}
+
+	locked := "no locked commit"
+	if p.LockedCommit != "" {
+		locked = "locked at " + p.LockedCommit
+	}
+	return fmt.Sprintf("%s: %s (%s), %s", p.Name, p.Repository, requested, locked)
+}
+
+// GitPods returns the pods with a :git external source, sorted by name.
+func (l PodfileLock) GitPods() []GitPod {
+	var pods []GitPod
+	for name, source := range l.ExternalSources {
+		repository, ok := source[":git"]
+		if !ok {
+			continue
+		}
+
+		pod := GitPod{
+			Name:       name,
+			Repository: repository,
+			Branch:     source[":branch"],
+			Tag:        source[":tag"],
+			Commit:     source[":commit"],
+		}
+		if checkout, ok := l.CheckoutOptions[name]; ok {
+			pod.LockedCommit = checkout[":commit"]
+		}
+		pods = append(pods, pod)
+	}
+
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	return pods
+}
+
+// GitCheckoutMismatch is a git pod whose installed checkout differs from the locked one.
+type GitCheckoutMismatch struct {
+	Pod       string
+	Locked    string
+	Installed string
+}
+
+func (m GitCheckoutMismatch) String() string {
+	installed := m.Installed
+	if installed == "" {
+		installed = "not installed"
+	}
+	return fmt.Sprintf("%s: locked %s, installed %s", m.Pod, m.Locked, installed)
+}
+
+// verifyGitCheckouts compares the CHECKOUT OPTIONS of Pods/Manifest.lock (the installed checkouts)
+// with the locked commits of the git pods. Pods without a locked commit or tag can not be verified.
+func verifyGitCheckouts(lock, manifest PodfileLock) []GitCheckoutMismatch {
+	installedPods := map[string]GitPod{}
+	for _, pod := range manifest.GitPods() {
+		installedPods[pod.Name] = pod
+	}
+
+	var mismatches []GitCheckoutMismatch
+	for _, pod := range lock.GitPods() {
+		locked := pod.LockedReference()
+		if locked == "" {
+			continue
+		}
+
+		installed := installedPods[pod.Name].LockedReference()
+		if !strings.EqualFold(installed, locked) {
+			mismatches = append(mismatches, GitCheckoutMismatch{Pod: pod.Name, Locked: locked, Installed: installed})
+		}
+	}
+	return mismatches
+}
+
+// checkGitPods lists the git pods with their locked commits and warns about the pods tracking a branch
+// without a locked commit. In strict mode the installed checkouts (Pods/Manifest.lock) need to match the commits locked
+// in the committed Podfile.lock (read before pod install, as pod install rewrites the CHECKOUT OPTIONS).
+func (s Step) checkGitPods(config Config, committedLock *PodfileLock) error {
+	if committedLock == nil {
+		s.logger.Warnf("No committed Podfile.lock, the git pods are not locked")
+		return nil
+	}
+	lock := *committedLock
+
+	gitPods := lock.GitPods()
+	if len(gitPods) == 0 {
+		return nil
+	}
+
+	s.logger.Printf("")
+	s.logger.Infof("Git pods")
+
+	for _, pod := range gitPods {
+		s.logger.Printf("- %s", pod)
+		if pod.IsTrackingBranch() && pod.LockedCommit == "" {
+			s.logger.Warnf("%s tracks a branch without a locked commit, every install can check out a different commit. Add a :commit to the Podfile.", pod.Name)
+		}
+	}
+
+	if !config.StrictGitPods {
+		return nil
+	}
+	if config.Command == "update" {
+		s.logger.Printf("pod update updates the locked commits, the installed git pods are not verified")
+		return nil
+	}
+
+	manifest, err := readPodfileLock(filepath.Join(config.PodfileDir, "Pods", "Manifest.lock"))
+	if err != nil {
+		return fmt.Errorf("failed to read Pods/Manifest.lock: %w", err)
+	}
+
+	mismatches := verifyGitCheckouts(lock, manifest)
+	if len(mismatches) == 0 {
+		s.logger.Donef("Installed git pods match the locked commits")
+		return nil
+	}
+
+	var items []string
+	for _, mismatch := range mismatches {
+		items = append(items, mismatch.String())
+	}
+	return fmt.Errorf("installed git pods do not match the locked commits:\n%s", strings.Join(items, "\n"))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"bitrise-steplib/steps-cocoapods-install/mocks"
+
+	"github.com/stretchr/testify/require"
+)
+
+const podfileLockWithGitPodsContent = `PODS:
+  - DevKit (0.3.0)
+  - NightlyKit (1.0.0)
+  - SwiftyJSON (5.0.1)
+
+DEPENDENCIES:
+  - DevKit (from ` + "`https://github.com/company/DevKit.git`" + `, branch ` + "`develop`" + `)
+  - NightlyKit (from ` + "`https://github.com/company/NightlyKit.git`" + `)
+  - SwiftyJSON (from ` + "`https://github.com/SwiftyJSON/SwiftyJSON.git`" + `, tag ` + "`5.0.1`" + `)
+
+EXTERNAL SOURCES:
+  DevKit:
+    :branch: develop
+    :git: https://github.com/company/DevKit.git
+  NightlyKit:
+    :git: https://github.com/company/NightlyKit.git
+  SwiftyJSON:
+    :git: https://github.com/SwiftyJSON/SwiftyJSON.git
+    :tag: 5.0.1
+
+CHECKOUT OPTIONS:
+  DevKit:
+    :commit: 2f1e3c4d5b6a7980112233445566778899aabbcc
+    :git: https://github.com/company/DevKit.git
+  SwiftyJSON:
+    :git: https://github.com/SwiftyJSON/SwiftyJSON.git
+    :tag: 5.0.1
+
+COCOAPODS: 1.11.3
+`
+
+func Test_GivenPodfileLockWithGitPods_WhenListingGitPods_ThenReturnsLockedCommits(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(podfileLockWithGitPodsContent))
+	require.NoError(t, err)
+
+	// When
+	pods := lock.GitPods()
+
+	// Then
+	require.Equal(t, []GitPod{
+		{Name: "DevKit", Repository: "https://github.com/company/DevKit.git", Branch: "develop", LockedCommit: "2f1e3c4d5b6a7980112233445566778899aabbcc"},
+		{Name: "NightlyKit", Repository: "https://github.com/company/NightlyKit.git"},
+		{Name: "SwiftyJSON", Repository: "https://github.com/SwiftyJSON/SwiftyJSON.git", Tag: "5.0.1"},
+	}, pods)
+	require.True(t, pods[0].IsTrackingBranch())
+	require.True(t, pods[1].IsTrackingBranch())
+	require.False(t, pods[2].IsTrackingBranch())
+	require.Equal(t, "DevKit: https://github.com/company/DevKit.git (branch develop), locked at 2f1e3c4d5b6a7980112233445566778899aabbcc", pods[0].String())
+	require.Equal(t, "NightlyKit: https://github.com/company/NightlyKit.git (default branch), no locked commit", pods[1].String())
+}
+
+func Test_GivenDifferentInstalledCheckout_WhenVerifyingGitCheckouts_ThenReturnsMismatches(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(podfileLockWithGitPodsContent))
+	require.NoError(t, err)
+	manifest, err := parsePodfileLock([]byte(podfileLockWithGitPodsContent))
+	require.NoError(t, err)
+	manifest.CheckoutOptions["DevKit"][":commit"] = "0000000000000000000000000000000000000000"
+	delete(manifest.ExternalSources, "SwiftyJSON")
+
+	// When
+	mismatches := verifyGitCheckouts(lock, manifest)
+
+	// Then
+	require.Equal(t, []GitCheckoutMismatch{
+		{Pod: "DevKit", Locked: "2f1e3c4d5b6a7980112233445566778899aabbcc", Installed: "0000000000000000000000000000000000000000"},
+		{Pod: "SwiftyJSON", Locked: "5.0.1"},
+	}, mismatches)
+	require.Equal(t, "SwiftyJSON: locked 5.0.1, installed not installed", mismatches[1].String())
+}
+
+func Test_GivenStrictGitPods_WhenInstalledCheckoutDiffers_ThenFails(t *testing.T) {
+	// Given
+	// pod install rewrote Podfile.lock to the installed checkout, the committed lockfile is read before the install.
+	installedContent := strings.ReplaceAll(podfileLockWithGitPodsContent, "2f1e3c4d5b6a7980112233445566778899aabbcc", "ffffffffffffffffffffffffffffffffffffffff")
+	dir := createTestProject(t, map[string]string{
+		"Podfile.lock":       installedContent,
+		"Pods/Manifest.lock": installedContent,
+	})
+	committedLock, err := parsePodfileLock([]byte(podfileLockWithGitPodsContent))
+	require.NoError(t, err)
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	err = step.checkGitPods(Config{PodfileDir: dir, StrictGitPods: true}, &committedLock)
+
+	// Then
+	require.EqualError(t, err, "installed git pods do not match the locked commits:\nDevKit: locked 2f1e3c4d5b6a7980112233445566778899aabbcc, installed ffffffffffffffffffffffffffffffffffffffff")
+}
+
+func Test_GivenNonStrictGitPods_WhenCheckingGitPods_ThenOnlyReports(t *testing.T) {
+	// Given
+	dir := createTestProject(t, map[string]string{
+		"Podfile.lock": podfileLockWithGitPodsContent,
+	})
+	committedLock, err := parsePodfileLock([]byte(podfileLockWithGitPodsContent))
+	require.NoError(t, err)
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	err = step.checkGitPods(Config{PodfileDir: dir}, &committedLock)
+
+	// Then
+	require.NoError(t, err)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	licenseViolationPolicyWarn = "warn"
+	licenseViolationPolicyFail = "fail"
+)
+
+const (
+	licenseViolationDenied     = "denied"
+	licenseViolationNotAllowed = "not allowed"
+	licenseViolationUnknown    = "unknown license"
+)
+
+const unknownLicense = "unknown"
+
+// LicensePolicy is the allow and deny list of the pod licenses.
+// The items are SPDX identifiers (or license names), an item matches its variants too,
+// for example GPL matches GPL-2.0-only, GPL-2.0+ and GPL-3.0-or-later, but not LGPL-2.1-only.
+// Both the SPDX identifier and the declared name of the license are matched. A deny item also matches the license names
+// mentioning it as a word (for example GPL matches GNU GPLv3), so that unrecognised names do not get past the deny list.
+type LicensePolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// LicenseViolation is a pod whose license is denied, not allowed or unknown (if an allow list is given).
+type LicenseViolation struct {
+	Pod     string
+	Version string
+	License string
+	Reason  string
+}
+
+// Check returns the pods violating the policy, a denied license is a violation even if it is allowed too.
+func (p LicensePolicy) Check(pods []SBOMPod) []LicenseViolation {
+	var violations []LicenseViolation
+	for _, pod := range pods {
+		violation := LicenseViolation{Pod: pod.Name, Version: pod.Version, License: licenseDisplayName(pod.License)}
+
+		switch {
+		case matchesAnyLicense(p.Deny, pod.License, true):
+			violation.Reason = licenseViolationDenied
+		case len(p.Allow) == 0:
+			continue
+		case pod.License.Name == "" && pod.License.SPDXID == "":
+			violation.Reason = licenseViolationUnknown
+		case !matchesAnyLicense(p.Allow, pod.License, false):
+			violation.Reason = licenseViolationNotAllowed
+		default:
+			continue
+		}
+
+		violations = append(violations, violation)
+	}
+	return violations
+}
+
+func matchesAnyLicense(items []string, license PodLicense, matchWords bool) bool {
+	for _, item := range items {
+		if licenseMatches(item, license, matchWords) {
+			return true
+		}
+	}
+	return false
+}
+
+func licenseMatches(item string, license PodLicense, matchWords bool) bool {
+	item = normalizeLicense(item)
+	if item == "" {
+		return false
+	}
+
+	for _, value := range []string{license.SPDXID, license.Name} {
+		value = normalizeLicense(value)
+		if value == "" {
+			continue
+		}
+		if isLicenseVariant(item, value) {
+			return true
+		}
+		if !matchWords {
+			continue
+		}
+		// The words of the name, for example: gnu-gpl-v3 -> gpl-v3, gplv3.
+		words := strings.Split(value, "-")
+		for i, word := range words {
+			if isLicenseVariant(item, strings.Join(words[i:], "-")) || isVersionedLicenseWord(item, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeLicense lowercases the license and replaces the whitespaces with dashes, for example: GNU GPL v3 -> gnu-gpl-v3.
+func normalizeLicense(license string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(license), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '_'
+	}), "-")
+}
+
+// isLicenseVariant tells if the license is the item or its variant, for example: gpl-3.0-or-later and gpl-2.0+ are variants of gpl.
+func isLicenseVariant(item, license string) bool {
+	return license == item || strings.HasPrefix(license, item+"-") || strings.HasPrefix(license, item+"+")
+}
+
+// isVersionedLicenseWord tells if the word is the item followed by a version, for example: gplv3 or gpl3 for gpl.
+func isVersionedLicenseWord(item, word string) bool {
+	version, ok := strings.CutPrefix(word, item)
+	if !ok {
+		return false
+	}
+	version = strings.TrimPrefix(version, "v")
+	return version != "" && unicode.IsDigit(rune(version[0]))
+}
+
+func licenseDisplayName(license PodLicense) string {
+	if license.SPDXID != "" {
+		return license.SPDXID
+	}
+	if license.Name != "" {
+		return license.Name
+	}
+	return unknownLicense
+}
+
+// licenseCounts returns the number of pods per license, in descending order of the count.
+func licenseCounts(pods []SBOMPod) []string {
+	counts := map[string]int{}
+	for _, pod := range pods {
+		counts[licenseDisplayName(pod.License)]++
+	}
+
+	var names []string
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var items []string
+	for _, name := range names {
+		items = append(items, fmt.Sprintf("%s: %d", name, counts[name]))
+	}
+	return items
+}
+
+// licenseAnnotation returns the markdown summary of the pod licenses and the violations.
+func licenseAnnotation(pods []SBOMPod, violations []LicenseViolation) string {
+	var b strings.Builder
+	b.WriteString("### CocoaPods licenses\n")
+	fmt.Fprintf(&b, "%d pods: %s\n", len(pods), strings.Join(licenseCounts(pods), ", "))
+
+	if len(violations) > 0 {
+		b.WriteString("\n| Pod | Version | License | Problem |\n| --- | --- | --- | --- |\n")
+		for _, violation := range violations {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", violation.Pod, violation.Version, violation.License, violation.Reason)
+		}
+	}
+
+	return b.String()
+}
+
+type acknowledgement struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	License       string `json:"license"`
+	SPDXID        string `json:"spdx_id,omitempty"`
+	LicenseSource string `json:"license_source,omitempty"`
+	Text          string `json:"text,omitempty"`
+}
+
+// acknowledgementsJSON returns the license (and the license text) of each pod, for the app's acknowledgements screen.
+func acknowledgementsJSON(pods []SBOMPod) ([]byte, error) {
+	acknowledgements := make([]acknowledgement, 0, len(pods))
+	for _, pod := range pods {
+		acknowledgements = append(acknowledgements, acknowledgement{
+			Name:          pod.Name,
+			Version:       pod.Version,
+			License:       licenseDisplayName(pod.License),
+			SPDXID:        pod.License.SPDXID,
+			LicenseSource: pod.License.Source,
+			Text:          pod.License.Text,
+		})
+	}
+	return json.MarshalIndent(acknowledgements, "", "  ")
+}
+
+// checkLicenses checks the pod licenses against the allow and deny lists, adds the license summary annotation
+// and writes the acknowledgements file.
+func (s Step) checkLicenses(config Config, pods []SBOMPod, result *Result) error {
+	if !config.LicenseCheck {
+		return nil
+	}
+
+	s.logger.Printf("")
+	s.logger.Infof("Checking pod licenses")
+
+	for _, item := range licenseCounts(pods) {
+		s.logger.Printf("- %s", item)
+	}
+
+	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	content, err := acknowledgementsJSON(pods)
+	if err != nil {
+		return fmt.Errorf("failed to encode acknowledgements: %w", err)
+	}
+	acknowledgementsPath := filepath.Join(config.OutputDir, "pods_acknowledgements.json")
+	if err := os.WriteFile(acknowledgementsPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write acknowledgements: %w", err)
+	}
+	s.logger.Donef("Acknowledgements: %s", acknowledgementsPath)
+	result.AcknowledgementsPath = acknowledgementsPath
+
+	violations := config.LicensePolicy.Check(pods)
+
+	style := "info"
+	if len(violations) > 0 {
+		style = "warning"
+		if config.FailOnLicenseIssues {
+			style = "error"
+		}
+	}
+	addAnnotation(s.cmdFactory, licenseAnnotation(pods, violations), style, "cocoapods-licenses")
+
+	if len(violations) == 0 {
+		s.logger.Donef("All pod licenses comply with the license policy")
+		return nil
+	}
+
+	var problems []string
+	for _, violation := range violations {
+		problems = append(problems, fmt.Sprintf("%s %s (%s): %s", violation.Pod, violation.Version, violation.License, violation.Reason))
+	}
+	message := fmt.Sprintf("%d pods violate the license policy:\n%s", len(violations), strings.Join(problems, "\n"))
+
+	if config.FailOnLicenseIssues {
+		return withErrorCategory(errorCategoryLicense, fmt.Errorf("%s", message))
+	}
+	s.logger.Warnf("%s", message)
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"bitrise-steplib/steps-cocoapods-install/mocks"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenLicensePolicy_WhenChecking_ThenReturnsViolations(t *testing.T) {
+	pods := []SBOMPod{
+		{Name: "Alamofire", Version: "5.6.4", License: PodLicense{Name: "MIT", SPDXID: "MIT"}},
+		{Name: "GPLKit", Version: "1.0.0", License: PodLicense{Name: "GNU GPL v3", SPDXID: "GPL-3.0-only"}},
+		{Name: "LGPLKit", Version: "1.0.0", License: PodLicense{Name: "LGPL-2.1", SPDXID: "LGPL-2.1-only"}},
+		{Name: "InternalKit", Version: "1.2.0", License: PodLicense{Name: "Commercial"}},
+		{Name: "Mystery", Version: "0.1.0"},
+	}
+
+	tests := []struct {
+		name   string
+		policy LicensePolicy
+		want   []LicenseViolation
+	}{
+		{name: "no lists", policy: LicensePolicy{}, want: nil},
+		{
+			name:   "deny list matches the license family",
+			policy: LicensePolicy{Deny: []string{"GPL", "AGPL"}},
+			want:   []LicenseViolation{{Pod: "GPLKit", Version: "1.0.0", License: "GPL-3.0-only", Reason: licenseViolationDenied}},
+		},
+		{
+			name:   "allow list",
+			policy: LicensePolicy{Allow: []string{"MIT", "lgpl-2.1-only", "commercial"}},
+			want: []LicenseViolation{
+				{Pod: "GPLKit", Version: "1.0.0", License: "GPL-3.0-only", Reason: licenseViolationNotAllowed},
+				{Pod: "Mystery", Version: "0.1.0", License: unknownLicense, Reason: licenseViolationUnknown},
+			},
+		},
+		{
+			name:   "deny wins over allow",
+			policy: LicensePolicy{Allow: []string{"MIT"}, Deny: []string{"MIT"}},
+			want: []LicenseViolation{
+				{Pod: "Alamofire", Version: "5.6.4", License: "MIT", Reason: licenseViolationDenied},
+				{Pod: "GPLKit", Version: "1.0.0", License: "GPL-3.0-only", Reason: licenseViolationNotAllowed},
+				{Pod: "LGPLKit", Version: "1.0.0", License: "LGPL-2.1-only", Reason: licenseViolationNotAllowed},
+				{Pod: "InternalKit", Version: "1.2.0", License: "Commercial", Reason: licenseViolationNotAllowed},
+				{Pod: "Mystery", Version: "0.1.0", License: unknownLicense, Reason: licenseViolationUnknown},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.policy.Check(pods))
+		})
+	}
+}
+
+func Test_GivenGPLVariants_WhenCheckingDenyList_ThenDeniesEveryVariant(t *testing.T) {
+	policy := LicensePolicy{Deny: []string{"GPL"}}
+
+	tests := []struct {
+		name       string
+		license    string
+		wantDenied bool
+	}{
+		{name: "or later SPDX identifier", license: "GPL-3.0-or-later", wantDenied: true},
+		{name: "plus suffix", license: "GPL-2.0+", wantDenied: true},
+		{name: "GNU prefix", license: "GNU GPL v3", wantDenied: true},
+		{name: "unmapped GNU name", license: "GNU GPLv3 or later", wantDenied: true},
+		{name: "full name", license: "GNU General Public License v3.0", wantDenied: true},
+		{name: "lowercase with underscore", license: "gpl_v2", wantDenied: true},
+		{name: "LGPL", license: "LGPL-2.1+", wantDenied: false},
+		{name: "unrelated license", license: "MIT", wantDenied: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The SPDX identifier is empty if the name is not recognised, as in the podspec license reader.
+			pod := SBOMPod{Name: "Kit", Version: "1.0.0", License: PodLicense{Name: tt.license, SPDXID: spdxLicenseID(tt.license)}}
+
+			violations := policy.Check([]SBOMPod{pod})
+
+			require.Equal(t, tt.wantDenied, len(violations) == 1, "violations: %v", violations)
+		})
+	}
+}
+
+func Test_GivenLicenseVariants_WhenCheckingAllowList_ThenMatchesIdentifierAndNamePrefix(t *testing.T) {
+	policy := LicensePolicy{Allow: []string{"MIT", "Apache-2.0"}}
+
+	tests := []struct {
+		license     string
+		wantAllowed bool
+	}{
+		{license: "MIT", wantAllowed: true},
+		{license: "Apache License, Version 2.0", wantAllowed: true},
+		{license: "Modified MIT", wantAllowed: false},
+		{license: "Apache-1.1", wantAllowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.license, func(t *testing.T) {
+			pod := SBOMPod{Name: "Kit", Version: "1.0.0", License: PodLicense{Name: tt.license, SPDXID: spdxLicenseID(tt.license)}}
+
+			require.Equal(t, tt.wantAllowed, len(policy.Check([]SBOMPod{pod})) == 0)
+		})
+	}
+}
+
+func Test_GivenLicenseViolations_WhenCreatingAnnotation_ThenSummarizesLicenses(t *testing.T) {
+	// Given
+	pods := []SBOMPod{
+		{Name: "Alamofire", Version: "5.6.4", License: PodLicense{Name: "MIT", SPDXID: "MIT"}},
+		{Name: "Kingfisher", Version: "7.0.0", License: PodLicense{Name: "MIT", SPDXID: "MIT"}},
+		{Name: "GPLKit", Version: "1.0.0", License: PodLicense{SPDXID: "GPL-3.0-only"}},
+	}
+	violations := []LicenseViolation{{Pod: "GPLKit", Version: "1.0.0", License: "GPL-3.0-only", Reason: licenseViolationDenied}}
+
+	// When
+	annotation := licenseAnnotation(pods, violations)
+
+	// Then
+	require.Equal(t, `### CocoaPods licenses
+3 pods: MIT: 2, GPL-3.0-only: 1
+
+| Pod | Version | License | Problem |
+| --- | --- | --- | --- |
+| GPLKit | 1.0.0 | GPL-3.0-only | denied |
+`, annotation)
+}
+
+func Test_GivenPods_WhenCreatingAcknowledgements_ThenContainsLicenseTexts(t *testing.T) {
+	// Given
+	pods := []SBOMPod{
+		{Name: "Alamofire", Version: "5.6.4", License: PodLicense{Name: "MIT", SPDXID: "MIT", Text: "Copyright (c) Alamofire", Source: licenseSourceAcknowledgements}},
+		{Name: "Mystery", Version: "0.1.0"},
+	}
+
+	// When
+	content, err := acknowledgementsJSON(pods)
+
+	// Then
+	require.NoError(t, err)
+	var acknowledgements []acknowledgement
+	require.NoError(t, json.Unmarshal(content, &acknowledgements))
+	require.Equal(t, []acknowledgement{
+		{Name: "Alamofire", Version: "5.6.4", License: "MIT", SPDXID: "MIT", LicenseSource: licenseSourceAcknowledgements, Text: "Copyright (c) Alamofire"},
+		{Name: "Mystery", Version: "0.1.0", License: unknownLicense},
+	}, acknowledgements)
+}
+
+func Test_GivenDeniedLicense_WhenCheckingLicenses_ThenFailsWithAnnotation(t *testing.T) {
+	// Given
+	pods := []SBOMPod{
+		{Name: "Alamofire", Version: "5.6.4", License: PodLicense{Name: "MIT", SPDXID: "MIT"}},
+		{Name: "GPLKit", Version: "1.0.0", License: PodLicense{SPDXID: "GPL-3.0-only"}},
+	}
+	outputDir := t.TempDir()
+
+	cmdFactory := new(mocks.CommandFactory)
+	annotateCmd := new(mocks.Command)
+	annotateCmd.On("Run").Return(nil).Once()
+	cmdFactory.On("Create", "bitrise", mock.MatchedBy(func(args []string) bool {
+		return containsAll(args, []string{"--style", "error", "--context", "cocoapods-licenses"})
+	}), mock.Anything).Return(annotateCmd).Once()
+
+	step := createTestStep(cmdFactory, fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	var result Result
+	err := step.checkLicenses(Config{
+		LicenseCheck:        true,
+		LicensePolicy:       LicensePolicy{Deny: []string{"GPL"}},
+		FailOnLicenseIssues: true,
+		OutputDir:           outputDir,
+	}, pods, &result)
+
+	// Then
+	require.EqualError(t, err, "1 pods violate the license policy:\nGPLKit 1.0.0 (GPL-3.0-only): denied")
+	require.Equal(t, errorCategoryLicense, errorCategory(err))
+	require.FileExists(t, result.AcknowledgementsPath)
+	cmdFactory.AssertExpectations(t)
+	annotateCmd.AssertExpectations(t)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"testing"
 
+	"github.com/bitrise-io/go-steputils/command/gems"
 	"github.com/stretchr/testify/require"
 )
 
@@ -156,3 +157,17 @@ func TestIsIncludedInGemfileLockVersionRanges(t *testing.T) {
 		require.False(t, isExcluded)
 	}
 }
+
+func TestBundleCheckCmdSlice(t *testing.T) {
+	t.Log("bundler version found")
+	{
+		cmdSlice := bundleCheckCmdSlice(gems.Version{Version: "2.4.10", Found: true})
+		require.Equal(t, []string{"bundle", "_2.4.10_", "check"}, cmdSlice)
+	}
+
+	t.Log("bundler version not found")
+	{
+		cmdSlice := bundleCheckCmdSlice(gems.Version{})
+		require.Equal(t, []string{"bundle", "check"}, cmdSlice)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// dirAndParents returns the directory and its parent directories up to (and including) the stop directory,
+// or only the directory if it is not inside the stop directory.
+func dirAndParents(dir, stopDir string) []string {
+	dir = filepath.Clean(dir)
+	stopDir = filepath.Clean(stopDir)
+
+	rel, err := filepath.Rel(stopDir, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return []string{dir}
+	}
+
+	dirs := []string{dir}
+	for current := dir; current != stopDir; {
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+		dirs = append(dirs, current)
+	}
+	return dirs
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirAndParents(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "repo")
+
+	tests := []struct {
+		name    string
+		dir     string
+		stopDir string
+		want    []string
+	}{
+		{name: "nested dir", dir: filepath.Join(root, "apps", "ios"), stopDir: root, want: []string{filepath.Join(root, "apps", "ios"), filepath.Join(root, "apps"), root}},
+		{name: "stop dir", dir: root, stopDir: root, want: []string{root}},
+		{name: "outside the stop dir", dir: filepath.Join(string(filepath.Separator), "other"), stopDir: root, want: []string{filepath.Join(string(filepath.Separator), "other")}},
+		{name: "filesystem root", dir: root, stopDir: string(filepath.Separator), want: []string{root, string(filepath.Separator)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, dirAndParents(tt.dir, tt.stopDir))
+		})
+	}
+}
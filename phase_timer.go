@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+)
+
+const (
+	phaseDiscovery           = "discovery"
+	phaseRubySelection       = "ruby_selection"
+	phaseBundlerInstall      = "bundler_install"
+	phaseCocoapodsGemInstall = "cocoapods_gem_install"
+	phaseRepoUpdate          = "repo_update"
+	phasePodInstall          = "pod_install"
+	phaseCacheCollection     = "cache_collection"
+)
+
+const slowPodsLimit = 10
+
+// PhaseTimer measures the duration of the Step phases and collects the per-pod timings of pod install.
+type PhaseTimer struct {
+	now func() time.Time
+
+	mu         sync.Mutex
+	phaseNames []string
+	phases     map[string]time.Duration
+	podTimings []PodTiming
+}
+
+// NewPhaseTimer ...
+func NewPhaseTimer() *PhaseTimer {
+	return newPhaseTimer(time.Now)
+}
+
+func newPhaseTimer(now func() time.Time) *PhaseTimer {
+	return &PhaseTimer{
+		now:    now,
+		phases: map[string]time.Duration{},
+	}
+}
+
+// Start starts measuring the phase and returns the function which stops it.
+// A phase can run multiple times (for example pod install is retried), the durations are summed up.
+func (t *PhaseTimer) Start(phase string) func() {
+	start := t.now()
+	return func() {
+		duration := t.now().Sub(start)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if _, ok := t.phases[phase]; !ok {
+			t.phaseNames = append(t.phaseNames, phase)
+		}
+		t.phases[phase] += duration
+	}
+}
+
+// AddPodTimings ...
+func (t *PhaseTimer) AddPodTimings(timings []PodTiming) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.podTimings = append(t.podTimings, timings...)
+}
+
+// PhaseDurations returns the measured phases in the order they were first completed.
+func (t *PhaseTimer) PhaseDurations() ([]string, map[string]time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	durations := map[string]time.Duration{}
+	for name, duration := range t.phases {
+		durations[name] = duration
+	}
+	return append([]string{}, t.phaseNames...), durations
+}
+
+// SlowestPods returns the pod timings in descending order of duration, at most limit items.
+func (t *PhaseTimer) SlowestPods(limit int) []PodTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timings := append([]PodTiming{}, t.podTimings...)
+	sort.SliceStable(timings, func(i, j int) bool {
+		return timings[i].Duration > timings[j].Duration
+	})
+	if len(timings) > limit {
+		timings = timings[:limit]
+	}
+	return timings
+}
+
+// PrintSummary prints the phase durations and the slowest pods as tables.
+func (t *PhaseTimer) PrintSummary(logger log.Logger) {
+	names, durations := t.PhaseDurations()
+	if len(names) == 0 {
+		return
+	}
+
+	logger.Printf("")
+	logger.Infof("Timing summary")
+	logger.Printf("%-24s %10s", "Phase", "Duration")
+
+	var total time.Duration
+	for _, name := range names {
+		logger.Printf("%-24s %10s", name, formatDuration(durations[name]))
+		total += durations[name]
+	}
+	logger.Printf("%-24s %10s", "total", formatDuration(total))
+
+	slowPods := t.SlowestPods(slowPodsLimit)
+	if len(slowPods) == 0 {
+		return
+	}
+
+	logger.Printf("")
+	logger.Infof("Slowest pods")
+	logger.Printf("%-40s %-12s %10s", "Pod", "Stage", "Duration")
+	for _, timing := range slowPods {
+		logger.Printf("%-40s %-12s %10s", timing.Pod, timing.Stage, formatDuration(timing.Duration))
+	}
+}
+
+// AnalyticsProperties returns the phase durations (in milliseconds) and the slowest pods for the analytics event.
+func (t *PhaseTimer) AnalyticsProperties() map[string]interface{} {
+	names, durations := t.PhaseDurations()
+
+	properties := map[string]interface{}{}
+	for _, name := range names {
+		properties[name+"_ms"] = durations[name].Milliseconds()
+	}
+
+	var slowPods []string
+	for _, timing := range t.SlowestPods(slowPodsLimit) {
+		slowPods = append(slowPods, fmt.Sprintf("%s:%s:%d", timing.Pod, timing.Stage, timing.Duration.Milliseconds()))
+	}
+	properties["slowest_pods"] = strings.Join(slowPods, ",")
+
+	return properties
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(10 * time.Millisecond).String()
+}
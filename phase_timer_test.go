@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenRepeatedPhases_WhenTiming_ThenSumsDurations(t *testing.T) {
+	// Given
+	clock := &fakeClock{current: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	timer := newPhaseTimer(clock.now)
+
+	// When
+	stop := timer.Start(phaseDiscovery)
+	clock.advance(2 * time.Second)
+	stop()
+
+	stop = timer.Start(phasePodInstall)
+	clock.advance(30 * time.Second)
+	stop()
+
+	stop = timer.Start(phaseRepoUpdate)
+	clock.advance(10 * time.Second)
+	stop()
+
+	stop = timer.Start(phasePodInstall)
+	clock.advance(20 * time.Second)
+	stop()
+
+	timer.AddPodTimings([]PodTiming{
+		{Pod: "Alamofire", Stage: podStageDownload, Duration: 3 * time.Second},
+		{Pod: "boost", Stage: podStageDownload, Duration: 40 * time.Second},
+		{Pod: "boost", Stage: podStageIntegration, Duration: 5 * time.Second},
+	})
+
+	// Then
+	names, durations := timer.PhaseDurations()
+	require.Equal(t, []string{phaseDiscovery, phasePodInstall, phaseRepoUpdate}, names)
+	require.Equal(t, map[string]time.Duration{
+		phaseDiscovery:  2 * time.Second,
+		phasePodInstall: 50 * time.Second,
+		phaseRepoUpdate: 10 * time.Second,
+	}, durations)
+
+	require.Equal(t, map[string]interface{}{
+		"discovery_ms":   int64(2000),
+		"pod_install_ms": int64(50000),
+		"repo_update_ms": int64(10000),
+		"slowest_pods":   "boost:download:40000,boost:integration:5000,Alamofire:download:3000",
+	}, timer.AnalyticsProperties())
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"howett.net/plist"
+)
+
+const (
+	licenseSourcePodspec          = "podspec"
+	licenseSourceAcknowledgements = "acknowledgements"
+	licenseSourceLicenseFile      = "license_file"
+)
+
+// PodLicense is the license of a pod, read from the local files only.
+type PodLicense struct {
+	// Name is the license as declared by the pod, for example: MIT or Apache License, Version 2.0.
+	Name string
+	// SPDXID is the SPDX identifier of the license, empty if the license is unknown.
+	SPDXID string
+	Text   string
+	Source string
+}
+
+var licenseFileNames = []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "LICENCE", "LICENCE.md", "LICENCE.txt", "COPYING"}
+
+var spdxLicenseIDs = map[string]string{
+	"mit":                             "MIT",
+	"the mit license":                 "MIT",
+	"apache":                          "Apache-2.0",
+	"apache 2":                        "Apache-2.0",
+	"apache 2.0":                      "Apache-2.0",
+	"apache-2.0":                      "Apache-2.0",
+	"apache license 2.0":              "Apache-2.0",
+	"apache license, version 2.0":     "Apache-2.0",
+	"apache license version 2.0":      "Apache-2.0",
+	"bsd":                             "BSD-3-Clause",
+	"new bsd":                         "BSD-3-Clause",
+	"bsd 3-clause":                    "BSD-3-Clause",
+	"bsd-3-clause":                    "BSD-3-Clause",
+	"bsd 2-clause":                    "BSD-2-Clause",
+	"bsd-2-clause":                    "BSD-2-Clause",
+	"isc":                             "ISC",
+	"zlib":                            "Zlib",
+	"mpl-2.0":                         "MPL-2.0",
+	"mozilla public license 2.0":      "MPL-2.0",
+	"agpl-3.0":                        "AGPL-3.0-only",
+	"agpl-3.0-only":                   "AGPL-3.0-only",
+	"agpl-3.0-or-later":               "AGPL-3.0-or-later",
+	"agpl-3.0+":                       "AGPL-3.0-or-later",
+	"agpl":                            "AGPL-3.0-only",
+	"agplv3":                          "AGPL-3.0-only",
+	"agplv3+":                         "AGPL-3.0-or-later",
+	"gnu agpl v3":                     "AGPL-3.0-only",
+	"lgpl-2.1":                        "LGPL-2.1-only",
+	"lgpl-2.1-only":                   "LGPL-2.1-only",
+	"lgpl-2.1-or-later":               "LGPL-2.1-or-later",
+	"lgpl-2.1+":                       "LGPL-2.1-or-later",
+	"lgplv2.1":                        "LGPL-2.1-only",
+	"lgpl-3.0":                        "LGPL-3.0-only",
+	"lgpl-3.0-only":                   "LGPL-3.0-only",
+	"lgpl-3.0-or-later":               "LGPL-3.0-or-later",
+	"lgpl-3.0+":                       "LGPL-3.0-or-later",
+	"lgplv3":                          "LGPL-3.0-only",
+	"lgplv3+":                         "LGPL-3.0-or-later",
+	"gnu lgpl v3":                     "LGPL-3.0-only",
+	"gpl-2.0":                         "GPL-2.0-only",
+	"gpl-2.0-only":                    "GPL-2.0-only",
+	"gpl-2.0-or-later":                "GPL-2.0-or-later",
+	"gpl-2.0+":                        "GPL-2.0-or-later",
+	"gpl-3.0":                         "GPL-3.0-only",
+	"gpl-3.0-only":                    "GPL-3.0-only",
+	"gpl-3.0-or-later":                "GPL-3.0-or-later",
+	"gpl-3.0+":                        "GPL-3.0-or-later",
+	"gplv2":                           "GPL-2.0-only",
+	"gplv2+":                          "GPL-2.0-or-later",
+	"gplv3":                           "GPL-3.0-only",
+	"gplv3+":                          "GPL-3.0-or-later",
+	"gpl v2":                          "GPL-2.0-only",
+	"gpl v3":                          "GPL-3.0-only",
+	"gnu gpl v2":                      "GPL-2.0-only",
+	"gnu gpl v3":                      "GPL-3.0-only",
+	"gnu gplv2":                       "GPL-2.0-only",
+	"gnu gplv3":                       "GPL-3.0-only",
+	"gnu general public license v2.0": "GPL-2.0-only",
+	"gnu general public license v3.0": "GPL-3.0-only",
+	"unlicense":                       "Unlicense",
+	"the unlicense":                   "Unlicense",
+	"boost":                           "BSL-1.0",
+	"boost software license":          "BSL-1.0",
+	"boost software license 1.0":      "BSL-1.0",
+	"boost software license, v. 1.0":  "BSL-1.0",
+	"bsl-1.0":                         "BSL-1.0",
+	"0bsd":                            "0BSD",
+	"wtfpl":                           "WTFPL",
+	"cc0-1.0":                         "CC0-1.0",
+}
+
+// spdxLicenseID returns the SPDX identifier of a license name, empty if the name is not recognised.
+func spdxLicenseID(name string) string {
+	return spdxLicenseIDs[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// spdxLicenseIDFromText recognises the most common license texts.
+func spdxLicenseIDFromText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	switch {
+	case strings.Contains(text, "Permission is hereby granted, free of charge"):
+		return "MIT"
+	case strings.Contains(text, "Apache License") && strings.Contains(text, "Version 2.0"):
+		return "Apache-2.0"
+	case strings.Contains(text, "Redistribution and use in source and binary forms"):
+		if strings.Contains(text, "Neither the name") || strings.Contains(text, "names of its contributors") {
+			return "BSD-3-Clause"
+		}
+		return "BSD-2-Clause"
+	case strings.Contains(text, "Permission to use, copy, modify, and/or distribute this software for any purpose"):
+		return "ISC"
+	case strings.Contains(text, "GNU AFFERO GENERAL PUBLIC LICENSE"):
+		return "AGPL-3.0-only"
+	case strings.Contains(text, "GNU LESSER GENERAL PUBLIC LICENSE"):
+		if strings.Contains(text, "Version 2.1") {
+			return "LGPL-2.1-only"
+		}
+		return "LGPL-3.0-only"
+	case strings.Contains(text, "GNU GENERAL PUBLIC LICENSE"):
+		if strings.Contains(text, "Version 2") {
+			return "GPL-2.0-only"
+		}
+		return "GPL-3.0-only"
+	case strings.Contains(text, "Boost Software License"):
+		return "BSL-1.0"
+	case strings.Contains(text, "This is free and unencumbered software released into the public domain"):
+		return "Unlicense"
+	default:
+		return ""
+	}
+}
+
+// readPodLicenses collects the license of each root pod from the local files, in order of precedence:
+// the podspec of the local and git pods (Pods/Local Podspecs), the acknowledgements generated by CocoaPods
+// and the license file in the pod's directory.
+// Pods without any license information are missing from the returned map.
+func readPodLicenses(podfileDir string, lock PodfileLock) map[string]PodLicense {
+	podsDir := filepath.Join(podfileDir, "Pods")
+	acknowledgements := readAcknowledgementLicenses(podsDir)
+
+	licenses := map[string]PodLicense{}
+	for _, name := range lock.RootPodNames() {
+		if license, ok := readPodspecLicense(podfileDir, podsDir, lock, name); ok {
+			licenses[name] = license
+		} else if license, ok := acknowledgements[name]; ok {
+			licenses[name] = license
+		} else if license, ok := readLicenseFile(podDir(podfileDir, podsDir, lock, name)); ok {
+			licenses[name] = license
+		}
+	}
+	return licenses
+}
+
+// podDir returns the directory of the pod's sources, the local directory for :path pods.
+func podDir(podfileDir, podsDir string, lock PodfileLock, name string) string {
+	if pth, ok := lock.ExternalSources[name][":path"]; ok {
+		if filepath.IsAbs(pth) {
+			return pth
+		}
+		return filepath.Join(podfileDir, pth)
+	}
+	return filepath.Join(podsDir, name)
+}
+
+type podspecLicenseModel struct {
+	License json.RawMessage `json:"license"`
+}
+
+func readPodspecLicense(podfileDir, podsDir string, lock PodfileLock, name string) (PodLicense, bool) {
+	content, err := os.ReadFile(filepath.Join(podsDir, "Local Podspecs", name+".podspec.json"))
+	if err != nil {
+		return PodLicense{}, false
+	}
+
+	var model podspecLicenseModel
+	if err := json.Unmarshal(content, &model); err != nil || len(model.License) == 0 {
+		return PodLicense{}, false
+	}
+
+	// The license is either the license type or an object with the type, the license file or the license text.
+	var license struct {
+		Type string `json:"type"`
+		File string `json:"file"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(model.License, &license.Type); err != nil {
+		if err := json.Unmarshal(model.License, &license); err != nil {
+			return PodLicense{}, false
+		}
+	}
+	if license.Type == "" {
+		return PodLicense{}, false
+	}
+
+	text := license.Text
+	if text == "" && license.File != "" {
+		if content, err := os.ReadFile(filepath.Join(podDir(podfileDir, podsDir, lock, name), license.File)); err == nil {
+			text = string(content)
+		}
+	}
+
+	return PodLicense{Name: license.Type, SPDXID: spdxLicenseID(license.Type), Text: text, Source: licenseSourcePodspec}, true
+}
+
+type acknowledgementsModel struct {
+	PreferenceSpecifiers []struct {
+		Title      string `plist:"Title"`
+		License    string `plist:"License"`
+		FooterText string `plist:"FooterText"`
+	} `plist:"PreferenceSpecifiers"`
+}
+
+// readAcknowledgementLicenses reads the Pods-<target>-acknowledgements.plist files generated by pod install.
+func readAcknowledgementLicenses(podsDir string) map[string]PodLicense {
+	licenses := map[string]PodLicense{}
+
+	pths, err := filepath.Glob(filepath.Join(podsDir, "Target Support Files", "*", "*-acknowledgements.plist"))
+	if err != nil {
+		return licenses
+	}
+
+	for _, pth := range pths {
+		content, err := os.ReadFile(pth)
+		if err != nil {
+			continue
+		}
+
+		var model acknowledgementsModel
+		if _, err := plist.Unmarshal(content, &model); err != nil {
+			continue
+		}
+
+		for _, specifier := range model.PreferenceSpecifiers {
+			if specifier.Title == "" || specifier.License == "" {
+				continue
+			}
+			id := spdxLicenseID(specifier.License)
+			if id == "" {
+				id = spdxLicenseIDFromText(specifier.FooterText)
+			}
+			licenses[specifier.Title] = PodLicense{
+				Name:   specifier.License,
+				SPDXID: id,
+				Text:   specifier.FooterText,
+				Source: licenseSourceAcknowledgements,
+			}
+		}
+	}
+
+	return licenses
+}
+
+func readLicenseFile(dir string) (PodLicense, bool) {
+	for _, name := range licenseFileNames {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+
+		text := string(content)
+		id := spdxLicenseIDFromText(text)
+		return PodLicense{Name: id, SPDXID: id, Text: text, Source: licenseSourceLicenseFile}, true
+	}
+	return PodLicense{}, false
+}
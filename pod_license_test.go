@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const acknowledgementsPlistContent = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PreferenceSpecifiers</key>
+	<array>
+		<dict>
+			<key>FooterText</key>
+			<string>This application makes use of the following third party libraries:</string>
+			<key>Title</key>
+			<string>Acknowledgements</string>
+			<key>Type</key>
+			<string>PSGroupSpecifier</string>
+		</dict>
+		<dict>
+			<key>FooterText</key>
+			<string>Copyright (c) 2014-2022 Alamofire Software Foundation</string>
+			<key>License</key>
+			<string>MIT</string>
+			<key>Title</key>
+			<string>Alamofire</string>
+			<key>Type</key>
+			<string>PSGroupSpecifier</string>
+		</dict>
+		<dict>
+			<key>FooterText</key>
+			<string>Proprietary</string>
+			<key>License</key>
+			<string>Commercial</string>
+			<key>Title</key>
+			<string>InternalKit</string>
+			<key>Type</key>
+			<string>PSGroupSpecifier</string>
+		</dict>
+	</array>
+</dict>
+</plist>
+`
+
+func Test_GivenPodsDirectory_WhenReadingLicenses_ThenUsesLocalFiles(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(podfileLockWithSourcesContent))
+	require.NoError(t, err)
+
+	projectDir := createTestProject(t, map[string]string{
+		"Pods/Target Support Files/Pods-App/Pods-App-acknowledgements.plist": acknowledgementsPlistContent,
+		"Pods/Local Podspecs/LocalKit.podspec.json":                          `{"name": "LocalKit", "license": {"type": "Apache License, Version 2.0", "file": "LICENSE"}}`,
+		"Pods/Local Podspecs/SwiftyJSON.podspec.json":                        `{"name": "SwiftyJSON", "license": "MIT"}`,
+		"../LocalKit/LICENSE":                                                "Apache License\nVersion 2.0, January 2004",
+		"Pods/FirebaseCore/LICENSE":                                          "Redistribution and use in source and binary forms, with or without modification",
+		"Pods/Firebase/LICENSE.txt":                                          "Licensed under the terms of a private agreement.",
+	})
+
+	// When
+	licenses := readPodLicenses(projectDir, lock)
+
+	// Then
+	require.Equal(t, map[string]PodLicense{
+		"Alamofire":    {Name: "MIT", SPDXID: "MIT", Text: "Copyright (c) 2014-2022 Alamofire Software Foundation", Source: licenseSourceAcknowledgements},
+		"InternalKit":  {Name: "Commercial", Text: "Proprietary", Source: licenseSourceAcknowledgements},
+		"LocalKit":     {Name: "Apache License, Version 2.0", SPDXID: "Apache-2.0", Text: "Apache License\nVersion 2.0, January 2004", Source: licenseSourcePodspec},
+		"SwiftyJSON":   {Name: "MIT", SPDXID: "MIT", Source: licenseSourcePodspec},
+		"FirebaseCore": {Name: "BSD-2-Clause", SPDXID: "BSD-2-Clause", Text: "Redistribution and use in source and binary forms, with or without modification", Source: licenseSourceLicenseFile},
+		"Firebase":     {Text: "Licensed under the terms of a private agreement.", Source: licenseSourceLicenseFile},
+	}, licenses)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// podExtraArgFlags lists the flags accepted in the pod_install_extra_args input per pod subcommand.
+// --no-repo-update and --verbose are not listed, as those are controlled by the Step.
+// The value is true for flags which require a value (--flag=value).
+var podExtraArgFlags = map[string]map[string]bool{
+	"install": {
+		"--deployment":        false,
+		"--clean-install":     false,
+		"--project-directory": true,
+		"--ansi":              false,
+		"--no-ansi":           false,
+		"--silent":            false,
+		"--allow-root":        false,
+	},
+	"update": {
+		"--sources":           true,
+		"--exclude-pods":      true,
+		"--clean-install":     false,
+		"--project-directory": true,
+		"--ansi":              false,
+		"--no-ansi":           false,
+		"--silent":            false,
+		"--allow-root":        false,
+	},
+}
+
+var envKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+const (
+	podWorkdirPodfileDir = "podfile-dir"
+	podWorkdirSourceRoot = "source-root"
+)
+
+// projectDirectoryArg returns the --project-directory argument pointing from the workdir to the Podfile's directory,
+// relative to the workdir if the Podfile is inside it. It is empty if the Podfile is in the workdir.
+func projectDirectoryArg(workdir, podfileDir string) string {
+	rel, err := filepath.Rel(workdir, podfileDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "--project-directory=" + podfileDir
+	}
+	if rel == "." {
+		return ""
+	}
+	return "--project-directory=" + rel
+}
+
+func hasPodExtraArg(args []string, name string) bool {
+	for _, arg := range args {
+		if flag, _, _ := strings.Cut(arg, "="); flag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePodExtraArgs splits the space separated extra arguments and validates them against the flags known for the pod subcommand.
+func parsePodExtraArgs(podCmd, extraArgs string) ([]string, error) {
+	args := strings.Fields(extraArgs)
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	knownFlags, ok := podExtraArgFlags[podCmd]
+	if !ok {
+		return nil, fmt.Errorf("unknown pod command: %s", podCmd)
+	}
+
+	for _, arg := range args {
+		name, _, hasValue := strings.Cut(arg, "=")
+		requiresValue, known := knownFlags[name]
+		if !known {
+			return nil, fmt.Errorf("unsupported argument for pod %s: %s, supported arguments: %s", podCmd, arg, strings.Join(sortedFlags(knownFlags), ", "))
+		}
+		if requiresValue != hasValue {
+			if requiresValue {
+				return nil, fmt.Errorf("argument requires a value: %s, use: %s=<value>", arg, name)
+			}
+			return nil, fmt.Errorf("argument does not take a value: %s", arg)
+		}
+	}
+
+	return args, nil
+}
+
+// parsePodEnvs parses the newline separated KEY=VALUE list of the pod_env input.
+func parsePodEnvs(podEnv string) ([]string, error) {
+	var envs []string
+	for _, line := range strings.Split(podEnv, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		key, _, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid environment variable: %s, expected format: KEY=VALUE", line)
+		}
+		if !envKeyRegexp.MatchString(key) {
+			return nil, fmt.Errorf("invalid environment variable key: %s", key)
+		}
+
+		envs = append(envs, line)
+	}
+	return envs, nil
+}
+
+// parsePluginVersions parses the pinned plugin gem versions, one `gem=version` pair per line.
+// The items are not comma separated, as a version requirement can have several parts: `cocoapods-binary=~> 0.4, >= 0.4.2`.
+func parsePluginVersions(s string) (map[string]string, error) {
+	var versions map[string]string
+	for _, line := range strings.Split(s, "\n") {
+		item := strings.TrimSpace(line)
+		if item == "" {
+			continue
+		}
+
+		gem, version, found := strings.Cut(item, "=")
+		gem, version = strings.TrimSpace(gem), strings.TrimSpace(version)
+		if !found || gem == "" || version == "" {
+			return nil, fmt.Errorf("invalid plugin version: %s, expected format: GEM=VERSION", item)
+		}
+		if versions == nil {
+			versions = map[string]string{}
+		}
+		versions[gem] = version
+	}
+	return versions, nil
+}
+
+// parseCocoapodsKeys parses the cocoapods-keys key to secret env mapping, one `KEY=SECRET_ENV` pair per line
+// (or comma separated). The errors refer to the items by their position, as an item could contain an expanded secret.
+func parseCocoapodsKeys(s string) (map[string]string, error) {
+	var keys map[string]string
+	for i, item := range parseList(s) {
+		key, secretEnv, found := strings.Cut(item, "=")
+		key, secretEnv = strings.TrimSpace(key), strings.TrimSpace(secretEnv)
+		if !found || !envKeyRegexp.MatchString(key) {
+			return nil, fmt.Errorf("invalid key mapping (item %d), expected format: KEY=SECRET_ENV", i+1)
+		}
+		if !envKeyRegexp.MatchString(secretEnv) {
+			return nil, fmt.Errorf("invalid secret env name for %s, the name of the env is needed (without $)", key)
+		}
+		if keys == nil {
+			keys = map[string]string{}
+		}
+		keys[key] = secretEnv
+	}
+	return keys, nil
+}
+
+// parseList parses a newline or comma separated list input, empty items are dropped.
+func parseList(s string) []string {
+	var items []string
+	for _, line := range strings.Split(s, "\n") {
+		for _, item := range strings.Split(line, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+func sortedFlags(flags map[string]bool) []string {
+	var names []string
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
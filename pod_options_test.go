@@ -0,0 +1,208 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenExtraArgs_WhenParsing_ThenValidatesAgainstSubcommandFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		podCmd    string
+		extraArgs string
+		want      []string
+		wantErr   string
+	}{
+		{
+			name:      "empty",
+			podCmd:    "install",
+			extraArgs: "  ",
+		},
+		{
+			name:      "install flags",
+			podCmd:    "install",
+			extraArgs: "--clean-install  --ansi\n--project-directory=ios",
+			want:      []string{"--clean-install", "--ansi", "--project-directory=ios"},
+		},
+		{
+			name:      "update flags",
+			podCmd:    "update",
+			extraArgs: "--exclude-pods=Alamofire,Kingfisher --sources=https://cdn.cocoapods.org/",
+			want:      []string{"--exclude-pods=Alamofire,Kingfisher", "--sources=https://cdn.cocoapods.org/"},
+		},
+		{
+			name:      "update flag for install",
+			podCmd:    "install",
+			extraArgs: "--exclude-pods=Alamofire",
+			wantErr:   "unsupported argument for pod install: --exclude-pods=Alamofire, supported arguments: --allow-root, --ansi, --clean-install, --deployment, --no-ansi, --project-directory, --silent",
+		},
+		{
+			name:      "flag controlled by the Step",
+			podCmd:    "update",
+			extraArgs: "--verbose",
+			wantErr:   "unsupported argument for pod update: --verbose, supported arguments: --allow-root, --ansi, --clean-install, --exclude-pods, --no-ansi, --project-directory, --silent, --sources",
+		},
+		{
+			name:      "missing value",
+			podCmd:    "update",
+			extraArgs: "--sources",
+			wantErr:   "argument requires a value: --sources, use: --sources=<value>",
+		},
+		{
+			name:      "unexpected value",
+			podCmd:    "install",
+			extraArgs: "--deployment=true",
+			wantErr:   "argument does not take a value: --deployment=true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePodExtraArgs(tt.podCmd, tt.extraArgs)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_GivenPodEnvList_WhenParsing_ThenReturnsEnvs(t *testing.T) {
+	tests := []struct {
+		name    string
+		podEnv  string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "empty",
+			podEnv: "",
+		},
+		{
+			name:   "multiple envs",
+			podEnv: "COCOAPODS_DISABLE_STATS=true\n\n  LANG=en_US.UTF-8  \nCP_HOME_DIR=/tmp/cocoapods\nEMPTY=\nWITH_EQUALS=a=b",
+			want:   []string{"COCOAPODS_DISABLE_STATS=true", "LANG=en_US.UTF-8", "CP_HOME_DIR=/tmp/cocoapods", "EMPTY=", "WITH_EQUALS=a=b"},
+		},
+		{
+			name:    "missing value",
+			podEnv:  "COCOAPODS_DISABLE_STATS",
+			wantErr: "invalid environment variable: COCOAPODS_DISABLE_STATS, expected format: KEY=VALUE",
+		},
+		{
+			name:    "invalid key",
+			podEnv:  "1KEY=value",
+			wantErr: "invalid environment variable key: 1KEY",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePodEnvs(tt.podEnv)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_GivenPluginVersionList_WhenParsing_ThenReturnsVersionsByGem(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "empty",
+			input: " \n",
+		},
+		{
+			name:  "multiple versions",
+			input: "cocoapods-binary=0.4.4\n  cocoapods-keys = 2.3.1 ",
+			want:  map[string]string{"cocoapods-binary": "0.4.4", "cocoapods-keys": "2.3.1"},
+		},
+		{
+			name:  "multi-part version requirement",
+			input: "cocoapods-binary=~> 0.4, >= 0.4.2\ncocoapods-keys=2.3.1",
+			want:  map[string]string{"cocoapods-binary": "~> 0.4, >= 0.4.2", "cocoapods-keys": "2.3.1"},
+		},
+		{
+			name:    "missing version",
+			input:   "cocoapods-keys",
+			wantErr: "invalid plugin version: cocoapods-keys, expected format: GEM=VERSION",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePluginVersions(tt.input)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_GivenCocoapodsKeysMapping_WhenParsing_ThenReturnsSecretEnvsByKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "empty",
+			input: "",
+		},
+		{
+			name:  "multiple keys",
+			input: "AnalyticsKey=ANALYTICS_KEY\n MapsAPIKey = MAPS_API_KEY ",
+			want:  map[string]string{"AnalyticsKey": "ANALYTICS_KEY", "MapsAPIKey": "MAPS_API_KEY"},
+		},
+		{
+			name:    "missing secret env",
+			input:   "AnalyticsKey=ANALYTICS_KEY\nsk_live_1234",
+			wantErr: "invalid key mapping (item 2), expected format: KEY=SECRET_ENV",
+		},
+		{
+			name:    "expanded secret",
+			input:   "AnalyticsKey=sk-live/1234",
+			wantErr: "invalid secret env name for AnalyticsKey, the name of the env is needed (without $)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCocoapodsKeys(tt.input)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_GivenWorkdir_WhenCreatingProjectDirectoryArg_ThenPointsToPodfileDir(t *testing.T) {
+	tests := []struct {
+		name       string
+		workdir    string
+		podfileDir string
+		want       string
+	}{
+		{name: "podfile in the workdir", workdir: "/project", podfileDir: "/project", want: ""},
+		{name: "podfile in a subdirectory", workdir: "/project", podfileDir: "/project/apps/ios", want: "--project-directory=apps/ios"},
+		{name: "podfile outside the workdir", workdir: "/project", podfileDir: "/shared/ios", want: "--project-directory=/shared/ios"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, projectDirectoryArg(tt.workdir, tt.podfileDir))
+		})
+	}
+}
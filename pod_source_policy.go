@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	podSourceOriginPodfile         = "Podfile source"
+	podSourceOriginPodfilePods     = "Podfile pods"
+	podSourceOriginSpecRepos       = "SPEC REPOS"
+	podSourceOriginExternalSources = "EXTERNAL SOURCES"
+)
+
+// specRepoNameURLs are the URLs of the spec repos Podfile.lock refers to by name.
+var specRepoNameURLs = map[string]string{
+	"trunk":  "https://cdn.cocoapods.org/",
+	"master": specsRepoURL,
+}
+
+// PodSourcePolicy is the allow list of the pod sources. An item is a spec repo URL or a git host pattern,
+// for example: https://cdn.cocoapods.org/, github.com/company, *.company.com.
+// An item matches the sources on the same host (and under the same path), `*` matches within a path segment.
+type PodSourcePolicy struct {
+	Allowed []string
+}
+
+// PodSourceViolation is a source that is not allowed by the policy.
+type PodSourceViolation struct {
+	// Pods are the pods from the source, empty for the Podfile sources.
+	Pods   []string
+	Source string
+	Origin string
+}
+
+func (v PodSourceViolation) String() string {
+	if len(v.Pods) == 0 {
+		return fmt.Sprintf("%s (%s)", v.Source, v.Origin)
+	}
+	return fmt.Sprintf("%s (%s): %s", v.Source, v.Origin, strings.Join(v.Pods, ", "))
+}
+
+// IsAllowed tells if the source (spec repo or git URL) matches an item of the allow list.
+func (p PodSourcePolicy) IsAllowed(source string) bool {
+	segments := strings.Split(normalizeSourceURL(source), "/")
+	for _, item := range p.Allowed {
+		pattern := normalizeSourceURL(item)
+		if pattern == "" {
+			continue
+		}
+		for i := 1; i <= len(segments); i++ {
+			if matched, err := path.Match(pattern, strings.Join(segments[:i], "/")); err == nil && matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check returns the sources of the Podfile and Podfile.lock that are not allowed, local (:path) pods are always allowed.
+// The Podfile's sources are its source lines and the :git, :podspec and :http options of its pods.
+func (p PodSourcePolicy) Check(podfileSources []string, podfileExternalSources map[string]map[string]string, lock PodfileLock) []PodSourceViolation {
+	var violations []PodSourceViolation
+
+	for _, source := range podfileSources {
+		if !p.IsAllowed(source) {
+			violations = append(violations, PodSourceViolation{Source: source, Origin: podSourceOriginPodfile})
+		}
+	}
+
+	violations = append(violations, p.checkExternalSources(podfileExternalSources, podSourceOriginPodfilePods)...)
+
+	for _, repo := range sortedKeys(lock.SpecRepos) {
+		source := repo
+		if url, ok := specRepoNameURLs[repo]; ok {
+			source = url
+		}
+		if !p.IsAllowed(source) {
+			pods := append([]string{}, lock.SpecRepos[repo]...)
+			sort.Strings(pods)
+			violations = append(violations, PodSourceViolation{Pods: pods, Source: source, Origin: podSourceOriginSpecRepos})
+		}
+	}
+
+	return append(violations, p.checkExternalSources(lock.ExternalSources, podSourceOriginExternalSources)...)
+}
+
+// checkExternalSources returns the remote :git, :podspec and :http sources that are not allowed, grouped by source.
+func (p PodSourcePolicy) checkExternalSources(externalSources map[string]map[string]string, origin string) []PodSourceViolation {
+	podsBySource := map[string][]string{}
+	for _, pod := range sortedKeys(externalSources) {
+		for _, key := range []string{":git", ":podspec", ":http"} {
+			source, ok := externalSources[pod][key]
+			if !ok || !isRemoteSource(source) {
+				continue
+			}
+			if !p.IsAllowed(source) {
+				podsBySource[source] = append(podsBySource[source], pod)
+			}
+		}
+	}
+
+	var violations []PodSourceViolation
+	for _, source := range sortedKeys(podsBySource) {
+		violations = append(violations, PodSourceViolation{Pods: podsBySource[source], Source: source, Origin: origin})
+	}
+	return violations
+}
+
+// isRemoteSource tells if a :podspec or :http source is a URL, not a local file.
+func isRemoteSource(source string) bool {
+	return strings.Contains(source, "://") || strings.HasPrefix(source, "git@")
+}
+
+// normalizeSourceURL returns the host and path of a URL without the scheme, the user, the port and the .git suffix,
+// for example: git@github.com:company/Specs.git -> github.com/company/specs.
+func normalizeSourceURL(source string) string {
+	source = strings.ToLower(strings.TrimSpace(source))
+	if i := strings.Index(source, "://"); i >= 0 {
+		source = source[i+len("://"):]
+	} else if i := strings.Index(source, "@"); i >= 0 && strings.Contains(source[i:], ":") {
+		// scp-like git URL: user@host:path
+		source = strings.Replace(source[i+1:], ":", "/", 1)
+	}
+	if i := strings.Index(source, "@"); i >= 0 && (strings.Index(source, "/") < 0 || i < strings.Index(source, "/")) {
+		source = source[i+1:]
+	}
+
+	host, rest, _ := strings.Cut(source, "/")
+	if i := strings.Index(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+
+	rest = strings.TrimSuffix(strings.Trim(rest, "/"), ".git")
+	if rest == "" {
+		return host
+	}
+	return host + "/" + rest
+}
+
+// checkPodSources checks the Podfile sources and the spec repos and external sources of Podfile.lock
+// against the allowed_pod_sources input, before any pod is downloaded.
+func (s Step) checkPodSources(config Config) error {
+	if len(config.PodSourcePolicy.Allowed) == 0 {
+		return nil
+	}
+
+	s.logger.Printf("")
+	s.logger.Infof("Checking pod sources")
+
+	sources, err := podfileSources(config.PodfilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read Podfile sources: %w", err)
+	}
+	externalSources, err := podfileExternalSources(config.PodfilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read Podfile sources: %w", err)
+	}
+
+	var lock PodfileLock
+	if config.PodfileLockPath != "" {
+		if lock, err = readPodfileLock(config.PodfileLockPath); err != nil {
+			return fmt.Errorf("failed to read Podfile.lock: %w", err)
+		}
+	} else {
+		s.logger.Warnf("No Podfile.lock, only the Podfile sources are checked")
+	}
+
+	violations := config.PodSourcePolicy.Check(sources, externalSources, lock)
+	if len(violations) == 0 {
+		s.logger.Donef("All pod sources are allowed")
+		return nil
+	}
+	return podSourceViolationsError(violations)
+}
+
+// checkInstalledPodSources checks the spec repos and external sources of the Podfile.lock written by pod install,
+// which also lists the sources the Podfile did not declare explicitly (for example the default trunk spec repo).
+func (s Step) checkInstalledPodSources(config Config) error {
+	if len(config.PodSourcePolicy.Allowed) == 0 {
+		return nil
+	}
+
+	lock, err := readPodfileLock(filepath.Join(config.PodfileDir, "Podfile.lock"))
+	if err != nil {
+		return fmt.Errorf("failed to read Podfile.lock: %w", err)
+	}
+
+	violations := config.PodSourcePolicy.Check(nil, nil, lock)
+	if len(violations) == 0 {
+		s.logger.Donef("All installed pod sources are allowed")
+		return nil
+	}
+	return podSourceViolationsError(violations)
+}
+
+func podSourceViolationsError(violations []PodSourceViolation) error {
+	var items []string
+	for _, violation := range violations {
+		items = append(items, violation.String())
+	}
+	return fmt.Errorf("pods from sources not allowed by allowed_pod_sources:\n%s", strings.Join(items, "\n"))
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"bitrise-steplib/steps-cocoapods-install/mocks"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeSourceURL(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{source: "https://cdn.cocoapods.org/", expected: "cdn.cocoapods.org"},
+		{source: "https://github.com/CocoaPods/Specs.git", expected: "github.com/cocoapods/specs"},
+		{source: "git@github.com:company/Specs.git", expected: "github.com/company/specs"},
+		{source: "ssh://git@git.company.com:7999/ios/specs.git", expected: "git.company.com/ios/specs"},
+		{source: "https://user@bitbucket.org/company/kit.git/", expected: "bitbucket.org/company/kit"},
+		{source: "*.company.com", expected: "*.company.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			require.Equal(t, tt.expected, normalizeSourceURL(tt.source))
+		})
+	}
+}
+
+func TestPodSourcePolicy_IsAllowed(t *testing.T) {
+	policy := PodSourcePolicy{Allowed: []string{"https://cdn.cocoapods.org/", "github.com/company", "*.company.com"}}
+
+	tests := []struct {
+		source   string
+		expected bool
+	}{
+		{source: "https://cdn.cocoapods.org/", expected: true},
+		{source: "https://github.com/company/Specs.git", expected: true},
+		{source: "git@github.com:company/Kit.git", expected: true},
+		{source: "https://github.com/companyx/Kit.git", expected: false},
+		{source: "https://github.com/CocoaPods/Specs.git", expected: false},
+		{source: "https://git.company.com/ios/specs.git", expected: true},
+		{source: "https://company.com.evil.io/specs.git", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			require.Equal(t, tt.expected, policy.IsAllowed(tt.source))
+		})
+	}
+}
+
+func Test_GivenUnapprovedSources_WhenCheckingPolicy_ThenReturnsViolations(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(podfileLockWithSourcesContent))
+	require.NoError(t, err)
+	policy := PodSourcePolicy{Allowed: []string{"https://cdn.cocoapods.org/"}}
+
+	// When
+	violations := policy.Check([]string{"https://cdn.cocoapods.org/", "https://github.com/company/Specs.git"}, nil, lock)
+
+	// Then
+	require.Equal(t, []PodSourceViolation{
+		{Source: "https://github.com/company/Specs.git", Origin: podSourceOriginPodfile},
+		{Pods: []string{"InternalKit"}, Source: "https://github.com/company/Specs.git", Origin: podSourceOriginSpecRepos},
+		{Pods: []string{"SwiftyJSON"}, Source: "https://github.com/SwiftyJSON/SwiftyJSON.git", Origin: podSourceOriginExternalSources},
+	}, violations)
+	require.Equal(t, "https://github.com/SwiftyJSON/SwiftyJSON.git (EXTERNAL SOURCES): SwiftyJSON", violations[2].String())
+}
+
+func Test_GivenUnapprovedSource_WhenCheckingPodSources_ThenFailsWithAffectedPods(t *testing.T) {
+	// Given
+	dir := createTestProject(t, map[string]string{
+		"Podfile":      "source 'https://cdn.cocoapods.org/'\nsource 'https://github.com/company/Specs.git'\n",
+		"Podfile.lock": podfileLockWithSourcesContent,
+	})
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	err := step.checkPodSources(Config{
+		PodfilePath:     filepath.Join(dir, "Podfile"),
+		PodfileLockPath: filepath.Join(dir, "Podfile.lock"),
+		PodSourcePolicy: PodSourcePolicy{Allowed: []string{"https://cdn.cocoapods.org/", "github.com/company"}},
+	})
+
+	// Then
+	require.EqualError(t, err, "pods from sources not allowed by allowed_pod_sources:\nhttps://github.com/SwiftyJSON/SwiftyJSON.git (EXTERNAL SOURCES): SwiftyJSON")
+}
+
+func Test_GivenUnapprovedPodfileGitPod_WhenCheckingPodSources_ThenFailsBeforeInstall(t *testing.T) {
+	// Given
+	dir := createTestProject(t, map[string]string{
+		"Podfile": "source 'https://cdn.cocoapods.org/'\npod 'EvilKit', :git => 'https://evil.io/EvilKit.git'\n",
+	})
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	err := step.checkPodSources(Config{
+		PodfilePath:     filepath.Join(dir, "Podfile"),
+		PodSourcePolicy: PodSourcePolicy{Allowed: []string{"https://cdn.cocoapods.org/"}},
+	})
+
+	// Then
+	require.EqualError(t, err, "pods from sources not allowed by allowed_pod_sources:\nhttps://evil.io/EvilKit.git (Podfile pods): EvilKit")
+}
+
+func Test_GivenInstalledLockWithUnapprovedSpecRepo_WhenCheckingInstalledPodSources_ThenFails(t *testing.T) {
+	// Given
+	dir := createTestProject(t, map[string]string{
+		"Podfile.lock": podfileLockWithSourcesContent,
+	})
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	err := step.checkInstalledPodSources(Config{
+		PodfileDir:      dir,
+		PodSourcePolicy: PodSourcePolicy{Allowed: []string{"github.com/company", "github.com/SwiftyJSON"}},
+	})
+
+	// Then
+	require.EqualError(t, err, "pods from sources not allowed by allowed_pod_sources:\nhttps://cdn.cocoapods.org/ (SPEC REPOS): Alamofire, Firebase, FirebaseAnalytics, FirebaseCore")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	podStageDownload    = "download"
+	podStageIntegration = "integration"
+)
+
+// PodTiming is the time spent on a single pod in a stage of pod install.
+type PodTiming struct {
+	Pod      string
+	Stage    string
+	Duration time.Duration
+}
+
+var (
+	ansiEscapeRegexp       = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	podInstallingRegexp    = regexp.MustCompile(`^-> Installing (\S+) \(`)
+	podUsingRegexp         = regexp.MustCompile(`^-> Using (\S+) \(`)
+	podTargetInstallRegexp = regexp.MustCompile("^- Installing target `([^`]+)`")
+)
+
+// podOutputSectionHeaders end the pod being measured.
+var podOutputSectionHeaders = []string{
+	"Analyzing dependencies",
+	"Downloading dependencies",
+	"Generating Pods project",
+	"- Installing Pod Targets",
+	"- Installing Aggregate Targets",
+	"Integrating client project",
+	"Pod installation complete!",
+}
+
+// PodTimingParser is an io.Writer, which parses the output of `pod install --verbose`
+// and measures the time elapsed between the pod related lines.
+// A pod's download time is measured from its `-> Installing Pod (version)` line to the next pod or section,
+// its integration time from its `- Installing target` line to the next target or section.
+type PodTimingParser struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	buffer  []byte
+	current *PodTiming
+	start   time.Time
+	timings []PodTiming
+}
+
+// NewPodTimingParser ...
+func NewPodTimingParser() *PodTimingParser {
+	return newPodTimingParser(time.Now)
+}
+
+func newPodTimingParser(now func() time.Time) *PodTimingParser {
+	return &PodTimingParser{now: now}
+}
+
+// Write ...
+func (p *PodTimingParser) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buffer = append(p.buffer, b...)
+	for {
+		idx := bytes.IndexByte(p.buffer, '\n')
+		if idx < 0 {
+			break
+		}
+		p.processLine(string(p.buffer[:idx]))
+		p.buffer = p.buffer[idx+1:]
+	}
+
+	return len(b), nil
+}
+
+// Timings closes the pod being measured and returns the collected timings.
+func (p *PodTimingParser) Timings() []PodTiming {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.buffer) > 0 {
+		p.processLine(string(p.buffer))
+		p.buffer = nil
+	}
+	p.finishCurrent()
+
+	return append([]PodTiming{}, p.timings...)
+}
+
+func (p *PodTimingParser) processLine(line string) {
+	line = strings.TrimSpace(ansiEscapeRegexp.ReplaceAllString(line, ""))
+
+	if match := podInstallingRegexp.FindStringSubmatch(line); match != nil {
+		p.startPod(match[1], podStageDownload)
+		return
+	}
+	if match := podTargetInstallRegexp.FindStringSubmatch(line); match != nil {
+		p.startPod(match[1], podStageIntegration)
+		return
+	}
+	if podUsingRegexp.MatchString(line) {
+		p.finishCurrent()
+		return
+	}
+	for _, header := range podOutputSectionHeaders {
+		if strings.HasPrefix(line, header) {
+			p.finishCurrent()
+			return
+		}
+	}
+}
+
+func (p *PodTimingParser) startPod(pod, stage string) {
+	p.finishCurrent()
+	p.current = &PodTiming{Pod: pod, Stage: stage}
+	p.start = p.now()
+}
+
+func (p *PodTimingParser) finishCurrent() {
+	if p.current == nil {
+		return
+	}
+	p.current.Duration = p.now().Sub(p.start)
+	p.timings = append(p.timings, *p.current)
+	p.current = nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	current time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.current
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.current = c.current.Add(d)
+}
+
+func Test_GivenVerbosePodInstallOutput_WhenParsing_ThenMeasuresPods(t *testing.T) {
+	// Given
+	clock := &fakeClock{current: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	parser := newPodTimingParser(clock.now)
+	steps := []struct {
+		output  string
+		elapsed time.Duration
+	}{
+		{output: "Analyzing dependencies\nDownloading dependencies\n", elapsed: time.Second},
+		{output: "\x1b[32m-> Installing Alamofire (5.6.4)\x1b[0m\n  > Http download\n", elapsed: 3 * time.Second},
+		// Lines can arrive in multiple chunks.
+		{output: "-> Using Kingfisher (7.0.0)\n-> Install", elapsed: 0},
+		{output: "ing boost (1.76.0)\n", elapsed: 40 * time.Second},
+		{output: "Generating Pods project\n  - Installing Pod Targets\n", elapsed: time.Second},
+		{output: "    - Installing target `Alamofire` iOS 11.0\n", elapsed: 2 * time.Second},
+		{output: "    - Installing target `boost` iOS 11.0\n", elapsed: 5 * time.Second},
+		{output: "Integrating client project\n", elapsed: time.Second},
+	}
+
+	// When
+	for _, step := range steps {
+		_, err := parser.Write([]byte(step.output))
+		require.NoError(t, err)
+		clock.advance(step.elapsed)
+	}
+	timings := parser.Timings()
+
+	// Then
+	require.Equal(t, []PodTiming{
+		{Pod: "Alamofire", Stage: podStageDownload, Duration: 3 * time.Second},
+		{Pod: "boost", Stage: podStageDownload, Duration: 40 * time.Second},
+		{Pod: "Alamofire", Stage: podStageIntegration, Duration: 2 * time.Second},
+		{Pod: "boost", Stage: podStageIntegration, Duration: 5 * time.Second},
+	}, timings)
+}
+
+func Test_GivenInterruptedPodInstallOutput_WhenGettingTimings_ThenClosesLastPod(t *testing.T) {
+	// Given
+	clock := &fakeClock{current: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	parser := newPodTimingParser(clock.now)
+
+	// When
+	_, err := parser.Write([]byte("-> Installing Firebase (10.0.0)\n  > Git download"))
+	require.NoError(t, err)
+	clock.advance(time.Minute)
+	timings := parser.Timings()
+
+	// Then
+	require.Equal(t, []PodTiming{{Pod: "Firebase", Stage: podStageDownload, Duration: time.Minute}}, timings)
+}
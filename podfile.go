@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"os"
+	"regexp"
 	"strings"
 
 	"github.com/bitrise-io/go-utils/v2/command"
@@ -14,32 +15,121 @@ Your Podfile is still using the Specs repo. Switch to the CDN source for **faste
 Learn more about the **one-line change** [here](https://blog.cocoapods.org/CocoaPods-1.8.0-beta/).
 `
 
+const specsRepoURL = "https://github.com/CocoaPods/Specs.git"
+
+var (
+	podfileSourceRegexp = regexp.MustCompile(`^source\s*\(?\s*['"]([^'"]+)['"]`)
+	podfilePluginRegexp = regexp.MustCompile(`^plugin\s*\(?\s*['"]([^'"]+)['"]`)
+	podfilePodRegexp    = regexp.MustCompile(`^pod\s*\(?\s*['"]([^'"]+)['"](.*)`)
+	// podfileExternalSourceRegexp matches both the :git => 'url' and the git: 'url' hash syntax.
+	podfileExternalSourceRegexp = regexp.MustCompile(`(?::(git|podspec|http)\s*=>|\b(git|podspec|http):)\s*['"]([^'"]+)['"]`)
+)
+
 // isPodfileUsingSpecsRepo returns true if the Podfile contains a source 'https://github.com/CocoaPods/Specs.git'.
 // It returns false if the CDN source or any other 3rd party git source is used.
 func isPodfileUsingSpecsRepo(path string) (bool, error) {
-	file, err := os.Open(path)
+	sources, err := podfileSources(path)
 	if err != nil {
 		return false, err
 	}
+
+	for _, source := range sources {
+		if strings.EqualFold(source, specsRepoURL) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// podfileSources returns the spec repo URLs of the Podfile's source lines, in order of appearance.
+func podfileSources(path string) ([]string, error) {
+	return podfileDeclarations(path, podfileSourceRegexp)
+}
+
+// podfilePlugins returns the gem names of the Podfile's plugin lines, for example: plugin 'cocoapods-keys', {...}.
+func podfilePlugins(path string) ([]string, error) {
+	return podfileDeclarations(path, podfilePluginRegexp)
+}
+
+// podfileDeclarations returns the first argument of the Podfile lines matching the regexp, in order of appearance.
+func podfileDeclarations(path string, re *regexp.Regexp) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
 	defer file.Close()
 
-	specsRepoDefined := false
+	var values []string
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		cleanLine := strings.ReplaceAll(line, "\"", "'")
-		cleanLine = strings.ToLower(cleanLine)
-		if cleanLine == "source 'https://github.com/cocoapods/specs.git'" {
-			specsRepoDefined = true
-			break
+		if match := re.FindStringSubmatch(line); match != nil {
+			values = append(values, strings.TrimSpace(match[1]))
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return false, err
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// podfileExternalSources returns the :git, :podspec and :http options of the Podfile's pod lines by pod name,
+// in the format of the EXTERNAL SOURCES of Podfile.lock, for example: {"Kit": {":git": "https://github.com/company/Kit.git"}}.
+// A pod declaration continues on the next line if the line ends with a comma.
+func podfileExternalSources(path string) (map[string]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
 
-	return specsRepoDefined, nil
+	var declarations []string
+	continued := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if continued {
+			declarations[len(declarations)-1] += " " + line
+		} else {
+			declarations = append(declarations, line)
+		}
+		continued = strings.HasSuffix(line, ",")
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sources := map[string]map[string]string{}
+	for _, declaration := range declarations {
+		match := podfilePodRegexp.FindStringSubmatch(declaration)
+		if match == nil {
+			continue
+		}
+
+		for _, option := range podfileExternalSourceRegexp.FindAllStringSubmatch(match[2], -1) {
+			key := option[1]
+			if key == "" {
+				key = option[2]
+			}
+
+			name := strings.TrimSpace(match[1])
+			if sources[name] == nil {
+				sources[name] = map[string]string{}
+			}
+			sources[name][":"+key] = strings.TrimSpace(option[3])
+		}
+	}
+
+	return sources, nil
+}
+
+// addAnnotation adds a build annotation, annotations with the same context replace each other.
+func addAnnotation(cmdFactory command.Factory, markdown, style, context string) {
+	cmd := cmdFactory.Create("bitrise", []string{":annotations", "annotate", markdown, "--style", style, "--context", context}, nil)
+	_ = cmd.Run() // ignore error, this is best-effort
 }
 
 func addSpecsRepoAnnotation(cmdFactory command.Factory) {
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	podSourceTrunk   = "trunk"
+	podSourceGit     = "git"
+	podSourcePath    = "path"
+	podSourcePrivate = "private"
+)
+
+// PodRequirement is a pod name with an optional version requirement, for example: Alamofire (~> 5.6).
+type PodRequirement struct {
+	Name        string
+	Requirement string
+}
+
+// LockedPod is an item of the PODS section of Podfile.lock.
+type LockedPod struct {
+	Name         string
+	Version      string
+	Dependencies []PodRequirement
+}
+
+// PodfileLock is the parsed content of a Podfile.lock.
+type PodfileLock struct {
+	Pods             []LockedPod
+	Dependencies     []PodRequirement
+	SpecRepos        map[string][]string
+	ExternalSources  map[string]map[string]string
+	CheckoutOptions  map[string]map[string]string
+	SpecChecksums    map[string]string
+	PodfileChecksum  string
+	CocoapodsVersion string
+}
+
+type podfileLockModel struct {
+	Pods             []yaml.Node                  `yaml:"PODS"`
+	Dependencies     []string                     `yaml:"DEPENDENCIES"`
+	SpecRepos        map[string][]string          `yaml:"SPEC REPOS"`
+	ExternalSources  map[string]map[string]string `yaml:"EXTERNAL SOURCES"`
+	CheckoutOptions  map[string]map[string]string `yaml:"CHECKOUT OPTIONS"`
+	SpecChecksums    map[string]string            `yaml:"SPEC CHECKSUMS"`
+	PodfileChecksum  string                       `yaml:"PODFILE CHECKSUM"`
+	CocoapodsVersion string                       `yaml:"COCOAPODS"`
+}
+
+// readPodfileLock reads and parses the Podfile.lock at the given path.
+func readPodfileLock(pth string) (PodfileLock, error) {
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		return PodfileLock{}, err
+	}
+
+	lock, err := parsePodfileLock(content)
+	if err != nil {
+		return PodfileLock{}, fmt.Errorf("failed to parse %s: %w", pth, err)
+	}
+	return lock, nil
+}
+
+func parsePodfileLock(content []byte) (PodfileLock, error) {
+	var model podfileLockModel
+	if err := yaml.Unmarshal(content, &model); err != nil {
+		return PodfileLock{}, err
+	}
+
+	lock := PodfileLock{
+		SpecRepos:        model.SpecRepos,
+		ExternalSources:  model.ExternalSources,
+		CheckoutOptions:  model.CheckoutOptions,
+		SpecChecksums:    model.SpecChecksums,
+		PodfileChecksum:  model.PodfileChecksum,
+		CocoapodsVersion: model.CocoapodsVersion,
+	}
+
+	for _, node := range model.Pods {
+		pod, err := parseLockedPod(node)
+		if err != nil {
+			return PodfileLock{}, err
+		}
+		lock.Pods = append(lock.Pods, pod)
+	}
+
+	for _, dependency := range model.Dependencies {
+		lock.Dependencies = append(lock.Dependencies, parsePodRequirement(dependency))
+	}
+
+	return lock, nil
+}
+
+// parseLockedPod parses a PODS item, which is either a `Name (version)` string,
+// or a map with a single `Name (version)` key and the list of its dependencies.
+func parseLockedPod(node yaml.Node) (LockedPod, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		requirement := parsePodRequirement(node.Value)
+		return LockedPod{Name: requirement.Name, Version: requirement.Requirement}, nil
+	case yaml.MappingNode:
+		if len(node.Content) != 2 {
+			return LockedPod{}, fmt.Errorf("line %d: unexpected pod entry", node.Line)
+		}
+
+		requirement := parsePodRequirement(node.Content[0].Value)
+		var dependencies []string
+		if err := node.Content[1].Decode(&dependencies); err != nil {
+			return LockedPod{}, fmt.Errorf("line %d: invalid dependencies of %s: %w", node.Line, requirement.Name, err)
+		}
+
+		pod := LockedPod{Name: requirement.Name, Version: requirement.Requirement}
+		for _, dependency := range dependencies {
+			pod.Dependencies = append(pod.Dependencies, parsePodRequirement(dependency))
+		}
+		return pod, nil
+	default:
+		return LockedPod{}, fmt.Errorf("line %d: unexpected pod entry", node.Line)
+	}
+}
+
+func parsePodRequirement(s string) PodRequirement {
+	s = strings.TrimSpace(s)
+	name, requirement, found := strings.Cut(s, " (")
+	if !found {
+		return PodRequirement{Name: s}
+	}
+	return PodRequirement{Name: name, Requirement: strings.TrimSuffix(requirement, ")")}
+}
+
+// podRootName returns the name of the pod without the subspec, for example: Firebase for Firebase/Core.
+func podRootName(name string) string {
+	root, _, _ := strings.Cut(name, "/")
+	return root
+}
+
+// RootPodNames returns the sorted names of the locked pods without subspecs.
+func (l PodfileLock) RootPodNames() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, pod := range l.Pods {
+		root := podRootName(pod.Name)
+		if !seen[root] {
+			seen[root] = true
+			names = append(names, root)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PodSourceType tells where the pod comes from: trunk, a private spec repo, a git repository or a local path.
+// Returns an empty string if the source is not recorded (Podfile.lock of CocoaPods < 1.7).
+func (l PodfileLock) PodSourceType(rootName string) string {
+	if source, ok := l.ExternalSources[rootName]; ok {
+		if _, ok := source[":git"]; ok {
+			return podSourceGit
+		}
+		if _, ok := source[":path"]; ok {
+			return podSourcePath
+		}
+		return podSourcePrivate
+	}
+
+	for repo, pods := range l.SpecRepos {
+		for _, pod := range pods {
+			if pod != rootName {
+				continue
+			}
+			if repo == "trunk" || strings.HasPrefix(repo, "https://cdn.cocoapods.org") || strings.HasSuffix(repo, "CocoaPods/Specs.git") {
+				return podSourceTrunk
+			}
+			return podSourcePrivate
+		}
+	}
+
+	return ""
+}
+
+// PodCountsBySource returns the number of root pods per source type.
+func (l PodfileLock) PodCountsBySource() map[string]int {
+	counts := map[string]int{
+		podSourceTrunk:   0,
+		podSourceGit:     0,
+		podSourcePath:    0,
+		podSourcePrivate: 0,
+	}
+	for _, name := range l.RootPodNames() {
+		if source := l.PodSourceType(name); source != "" {
+			counts[source]++
+		}
+	}
+	return counts
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const podfileLockWithSourcesContent = `PODS:
+  - Alamofire (5.6.4)
+  - Firebase/Core (10.0.0):
+    - Firebase/CoreOnly
+    - FirebaseAnalytics (~> 10.0.0)
+  - Firebase/CoreOnly (10.0.0):
+    - FirebaseCore (= 10.0.0)
+  - FirebaseAnalytics (10.0.0)
+  - FirebaseCore (10.0.0)
+  - InternalKit (1.2.0)
+  - LocalKit (0.1.0)
+  - SwiftyJSON (5.0.1)
+
+DEPENDENCIES:
+  - Alamofire (~> 5.6)
+  - Firebase/Core
+  - InternalKit
+  - LocalKit (from ` + "`../LocalKit`" + `)
+  - SwiftyJSON (from ` + "`https://github.com/SwiftyJSON/SwiftyJSON.git`" + `, tag ` + "`5.0.1`" + `)
+
+SPEC REPOS:
+  https://github.com/company/Specs.git:
+    - InternalKit
+  trunk:
+    - Alamofire
+    - Firebase
+    - FirebaseAnalytics
+    - FirebaseCore
+
+EXTERNAL SOURCES:
+  LocalKit:
+    :path: "../LocalKit"
+  SwiftyJSON:
+    :git: https://github.com/SwiftyJSON/SwiftyJSON.git
+    :tag: 5.0.1
+
+CHECKOUT OPTIONS:
+  SwiftyJSON:
+    :git: https://github.com/SwiftyJSON/SwiftyJSON.git
+    :tag: 5.0.1
+
+SPEC CHECKSUMS:
+  Alamofire: 4e95d97098eacb88856099c4fc79b526a299e48c
+
+PODFILE CHECKSUM: 8f3a6ab1d4c4a4b5e5a0c4a6c0d6b0e2d0a1c3b4
+
+COCOAPODS: 1.11.3
+`
+
+func Test_GivenPodfileLock_WhenParsing_ThenReturnsSections(t *testing.T) {
+	// When
+	lock, err := parsePodfileLock([]byte(podfileLockWithSourcesContent))
+
+	// Then
+	require.NoError(t, err)
+	require.Len(t, lock.Pods, 8)
+	require.Equal(t, LockedPod{
+		Name:    "Firebase/Core",
+		Version: "10.0.0",
+		Dependencies: []PodRequirement{
+			{Name: "Firebase/CoreOnly"},
+			{Name: "FirebaseAnalytics", Requirement: "~> 10.0.0"},
+		},
+	}, lock.Pods[1])
+	require.Equal(t, PodRequirement{Name: "Alamofire", Requirement: "~> 5.6"}, lock.Dependencies[0])
+	require.Equal(t, PodRequirement{Name: "LocalKit", Requirement: "from `../LocalKit`"}, lock.Dependencies[3])
+	require.Equal(t, "5.0.1", lock.CheckoutOptions["SwiftyJSON"][":tag"])
+	require.Equal(t, "4e95d97098eacb88856099c4fc79b526a299e48c", lock.SpecChecksums["Alamofire"])
+	require.Equal(t, "8f3a6ab1d4c4a4b5e5a0c4a6c0d6b0e2d0a1c3b4", lock.PodfileChecksum)
+	require.Equal(t, "1.11.3", lock.CocoapodsVersion)
+}
+
+func Test_GivenPodfileLock_WhenCountingPods_ThenGroupsRootPodsBySource(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(podfileLockWithSourcesContent))
+	require.NoError(t, err)
+
+	// When
+	counts := lock.PodCountsBySource()
+
+	// Then
+	require.Equal(t, []string{"Alamofire", "Firebase", "FirebaseAnalytics", "FirebaseCore", "InternalKit", "LocalKit", "SwiftyJSON"}, lock.RootPodNames())
+	require.Equal(t, map[string]int{
+		podSourceTrunk:   4,
+		podSourceGit:     1,
+		podSourcePath:    1,
+		podSourcePrivate: 1,
+	}, counts)
+}
+
+func Test_GivenInvalidPodEntry_WhenParsing_ThenFails(t *testing.T) {
+	// When
+	_, err := parsePodfileLock([]byte("PODS:\n  - - Alamofire (5.6.4)\n"))
+
+	// Then
+	require.EqualError(t, err, "line 2: unexpected pod entry")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -70,6 +71,70 @@ func TestIsPodfileUsingSpecsRepo(t *testing.T) {
 	}
 }
 
+func TestPodfileSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Podfile")
+	if err := os.WriteFile(path, []byte(otherRepoPodfile+repoPodfileWithQuotes+"# source 'https://example.com/Specs.git'\n"), 0777); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sources, err := podfileSources(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"https://cdn.cocoapods.org/", "https://github.com/artsy/Specs.git", "https://github.com/CocoaPods/Specs.git"}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Expected %v, but got %v", expected, sources)
+	}
+}
+
+func TestPodfilePlugins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Podfile")
+	content := "plugin 'cocoapods-binary'\nplugin \"cocoapods-keys\", {\n  :project => \"App\"\n}\n# plugin 'cocoapods-art'\n"
+	if err := os.WriteFile(path, []byte(content), 0777); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	plugins, err := podfilePlugins(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"cocoapods-binary", "cocoapods-keys"}
+	if !reflect.DeepEqual(plugins, expected) {
+		t.Errorf("Expected %v, but got %v", expected, plugins)
+	}
+}
+
+func TestPodfileExternalSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Podfile")
+	content := `pod 'Alamofire', '~> 5.6'
+pod 'DevKit', :git => 'https://github.com/company/DevKit.git', :branch => 'develop'
+pod "JSONKit", podspec: "https://example.com/JSONKit.podspec"
+pod 'ZipKit',
+    :http => 'https://example.com/ZipKit.zip'
+pod 'LocalKit', :path => '../LocalKit'
+# pod 'OldKit', :git => 'https://github.com/company/OldKit.git'
+`
+	if err := os.WriteFile(path, []byte(content), 0777); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sources, err := podfileExternalSources(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]map[string]string{
+		"DevKit":  {":git": "https://github.com/company/DevKit.git"},
+		"JSONKit": {":podspec": "https://example.com/JSONKit.podspec"},
+		"ZipKit":  {":http": "https://example.com/ZipKit.zip"},
+	}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Expected %v, but got %v", expected, sources)
+	}
+}
+
 const cdnPodfile = `
 source 'https://cdn.cocoapods.org/'
 
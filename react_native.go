@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	reactNativeFlavorBare = "react-native"
+	reactNativeFlavorExpo = "expo"
+)
+
+const (
+	reactNativeNewArchDefault  = "default"
+	reactNativeNewArchEnabled  = "enabled"
+	reactNativeNewArchDisabled = "disabled"
+)
+
+var (
+	reactNativePodfileRegexp = regexp.MustCompile(`react_native_pods|use_react_native!|react-native/scripts`)
+	expoPodfileRegexp        = regexp.MustCompile(`use_expo_modules!|expo_modules_autolinking|expo/scripts/autolinking|expo-modules-autolinking`)
+	requireRelativeRegexp    = regexp.MustCompile(`(?m)^\s*require_relative\s*\(?\s*['"]([^'"]+)['"]`)
+)
+
+// ReactNativeProject is a React Native (or Expo) project the Podfile belongs to.
+type ReactNativeProject struct {
+	// Flavor is react-native or expo, empty if the Podfile is not a React Native Podfile.
+	Flavor string
+	// PackageDir is the directory of the package.json, where node_modules is installed.
+	PackageDir string
+	// RequiredFiles are the node_modules files the Podfile requires with require_relative.
+	RequiredFiles []string
+}
+
+// detectReactNativeProject checks if the Podfile uses the React Native (or Expo) CocoaPods scripts.
+// The package.json is searched in the Podfile's directory and its parent directories up to the root directory,
+// it defaults to the Podfile's parent directory (the usual ios/ layout).
+func detectReactNativeProject(podfilePath, rootDir string) (ReactNativeProject, error) {
+	content, err := os.ReadFile(podfilePath)
+	if err != nil {
+		return ReactNativeProject{}, err
+	}
+
+	var project ReactNativeProject
+	switch {
+	case expoPodfileRegexp.Match(content):
+		project.Flavor = reactNativeFlavorExpo
+	case reactNativePodfileRegexp.Match(content):
+		project.Flavor = reactNativeFlavorBare
+	default:
+		return ReactNativeProject{}, nil
+	}
+
+	podfileDir := filepath.Dir(podfilePath)
+	project.PackageDir = filepath.Dir(podfileDir)
+	for _, dir := range dirAndParents(podfileDir, rootDir) {
+		if _, err := os.Stat(filepath.Join(dir, "package.json")); err == nil {
+			project.PackageDir = dir
+			break
+		}
+	}
+
+	for _, match := range requireRelativeRegexp.FindAllSubmatch(content, -1) {
+		required := string(match[1])
+		if !strings.Contains(required, "node_modules") {
+			continue
+		}
+		if !strings.HasSuffix(required, ".rb") {
+			required += ".rb"
+		}
+		project.RequiredFiles = append(project.RequiredFiles, filepath.Join(podfileDir, required))
+	}
+
+	return project, nil
+}
+
+// MissingNodeModules returns the node_modules paths the Podfile needs, but are not installed.
+// The packages are resolved like Node does, so packages hoisted to a parent node_modules (monorepos) are found too.
+func (p ReactNativeProject) MissingNodeModules() []string {
+	packages := []string{"react-native"}
+	if p.Flavor == reactNativeFlavorExpo {
+		packages = append(packages, "expo")
+	}
+
+	var missing []string
+	for _, pkg := range packages {
+		if resolveNodePackage(p.PackageDir, pkg) == "" {
+			missing = append(missing, filepath.Join(p.PackageDir, "node_modules", pkg))
+		}
+	}
+	for _, pth := range p.RequiredFiles {
+		if _, err := os.Stat(pth); err != nil {
+			missing = append(missing, pth)
+		}
+	}
+	return missing
+}
+
+// resolveNodePackage returns the directory of the package in the node_modules of the package directory
+// or of its parent directories, empty if the package is not installed.
+func resolveNodePackage(packageDir, name string) string {
+	volumeRoot := filepath.VolumeName(packageDir) + string(filepath.Separator)
+	for _, dir := range dirAndParents(packageDir, volumeRoot) {
+		pth := filepath.Join(dir, "node_modules", name)
+		if _, err := os.Stat(pth); err == nil {
+			return pth
+		}
+	}
+	return ""
+}
+
+// reactNativeEnvs returns the envs the React Native CocoaPods scripts read.
+func reactNativeEnvs(newArch string, noFlipper bool) []string {
+	var envs []string
+	switch newArch {
+	case reactNativeNewArchEnabled:
+		envs = append(envs, "RCT_NEW_ARCH_ENABLED=1")
+	case reactNativeNewArchDisabled:
+		envs = append(envs, "RCT_NEW_ARCH_ENABLED=0")
+	}
+	if noFlipper {
+		envs = append(envs, "NO_FLIPPER=1")
+	}
+	return envs
+}
+
+// checkReactNativeProject detects React Native and Expo Podfiles, makes sure node_modules is installed
+// (the Podfile requires the React Native scripts from node_modules) and returns the React Native envs of the inputs.
+func (s Step) checkReactNativeProject(config Config) ([]string, error) {
+	envs := reactNativeEnvs(config.ReactNativeNewArch, config.ReactNativeNoFlipper)
+
+	project, err := detectReactNativeProject(config.PodfilePath, config.SourceRootPath)
+	if err != nil {
+		s.logger.Warnf("Failed to check if the Podfile belongs to a React Native project: %s", err)
+		return envs, nil
+	}
+
+	if project.Flavor == "" {
+		if len(envs) > 0 {
+			s.logger.Warnf("The Podfile is not a React Native Podfile, the React Native inputs may have no effect")
+		}
+		return envs, nil
+	}
+
+	s.logger.Printf("")
+	s.logger.Infof("Checking %s project", project.Flavor)
+	s.logger.Printf("package.json directory: %s", project.PackageDir)
+
+	if missing := project.MissingNodeModules(); len(missing) > 0 {
+		return nil, fmt.Errorf("node_modules is not installed, the %s Podfile requires it. Install the node modules (for example with npm ci, yarn install or npx expo prebuild) before this Step.\nMissing:\n%s",
+			project.Flavor, strings.Join(missing, "\n"))
+	}
+	s.logger.Donef("node_modules is installed")
+
+	return envs, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"bitrise-steplib/steps-cocoapods-install/mocks"
+
+	"github.com/stretchr/testify/require"
+)
+
+const reactNativePodfile = `require_relative '../node_modules/react-native/scripts/react_native_pods'
+require_relative '../node_modules/@react-native-community/cli-platform-ios/native_modules'
+
+platform :ios, min_ios_version_supported
+prepare_react_native_project!
+
+target 'App' do
+  config = use_native_modules!
+  use_react_native!(:path => config[:reactNativePath])
+end
+`
+
+const expoPodfile = `require File.join(File.dirname(` + "`node --print \"require.resolve('expo/package.json')\"`" + `), "scripts/autolinking")
+require File.join(File.dirname(` + "`node --print \"require.resolve('react-native/package.json')\"`" + `), "scripts/react_native_pods")
+
+target 'App' do
+  use_expo_modules!
+  use_react_native!
+end
+`
+
+func Test_GivenPodfiles_WhenDetectingReactNativeProject_ThenReturnsFlavorAndPackageDir(t *testing.T) {
+	tests := []struct {
+		name         string
+		podfile      string
+		wantFlavor   string
+		wantRequired []string
+	}{
+		{
+			name:         "react native",
+			podfile:      reactNativePodfile,
+			wantFlavor:   reactNativeFlavorBare,
+			wantRequired: []string{"ios/../node_modules/react-native/scripts/react_native_pods.rb", "ios/../node_modules/@react-native-community/cli-platform-ios/native_modules.rb"},
+		},
+		{
+			name:       "expo",
+			podfile:    expoPodfile,
+			wantFlavor: reactNativeFlavorExpo,
+		},
+		{
+			name:    "native app",
+			podfile: "platform :ios, '13.0'\ntarget 'App' do\n  pod 'Alamofire'\nend\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := createTestProject(t, map[string]string{
+				"package.json": "{}",
+				"ios/Podfile":  tt.podfile,
+			})
+
+			project, err := detectReactNativeProject(filepath.Join(dir, "ios", "Podfile"), dir)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantFlavor, project.Flavor)
+			if tt.wantFlavor == "" {
+				return
+			}
+			require.Equal(t, dir, project.PackageDir)
+			var wantRequired []string
+			for _, pth := range tt.wantRequired {
+				wantRequired = append(wantRequired, filepath.Join(dir, pth))
+			}
+			require.Equal(t, wantRequired, project.RequiredFiles)
+		})
+	}
+}
+
+func Test_GivenInstalledNodeModules_WhenCheckingMissingNodeModules_ThenReturnsMissingPaths(t *testing.T) {
+	// Given
+	dir := createTestProject(t, map[string]string{
+		"package.json": "{}",
+		"node_modules/react-native/scripts/react_native_pods.rb": "",
+		"ios/Podfile": reactNativePodfile,
+	})
+	project, err := detectReactNativeProject(filepath.Join(dir, "ios", "Podfile"), dir)
+	require.NoError(t, err)
+
+	// When
+	missing := project.MissingNodeModules()
+
+	// Then
+	require.Equal(t, []string{filepath.Join(dir, "node_modules", "@react-native-community", "cli-platform-ios", "native_modules.rb")}, missing)
+}
+
+func Test_GivenPackagesHoistedToMonorepoRoot_WhenCheckingMissingNodeModules_ThenResolvesParentNodeModules(t *testing.T) {
+	// Given
+	dir := createTestProject(t, map[string]string{
+		"package.json":                           "{}",
+		"node_modules/react-native/package.json": "{}",
+		"node_modules/expo/package.json":         "{}",
+		"apps/mobile/package.json":               "{}",
+		"apps/mobile/ios/Podfile":                expoPodfile,
+	})
+	project, err := detectReactNativeProject(filepath.Join(dir, "apps", "mobile", "ios", "Podfile"), dir)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "apps", "mobile"), project.PackageDir)
+
+	// When
+	missing := project.MissingNodeModules()
+
+	// Then
+	require.Empty(t, missing)
+}
+
+func Test_GivenReactNativeInputs_WhenCreatingEnvs_ThenReturnsReactNativeEnvs(t *testing.T) {
+	require.Empty(t, reactNativeEnvs(reactNativeNewArchDefault, false))
+	require.Equal(t, []string{"RCT_NEW_ARCH_ENABLED=1", "NO_FLIPPER=1"}, reactNativeEnvs(reactNativeNewArchEnabled, true))
+	require.Equal(t, []string{"RCT_NEW_ARCH_ENABLED=0"}, reactNativeEnvs(reactNativeNewArchDisabled, false))
+}
+
+func Test_GivenExpoProjectWithoutNodeModules_WhenCheckingProject_ThenFailsEarly(t *testing.T) {
+	// Given
+	dir := createTestProject(t, map[string]string{
+		"package.json": "{}",
+		"ios/Podfile":  expoPodfile,
+	})
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	envs, err := step.checkReactNativeProject(Config{
+		SourceRootPath:     dir,
+		PodfilePath:        filepath.Join(dir, "ios", "Podfile"),
+		ReactNativeNewArch: reactNativeNewArchEnabled,
+	})
+
+	// Then
+	require.Nil(t, envs)
+	require.EqualError(t, err, "node_modules is not installed, the expo Podfile requires it. Install the node modules (for example with npm ci, yarn install or npx expo prebuild) before this Step.\nMissing:\n"+
+		filepath.Join(dir, "node_modules", "react-native")+"\n"+filepath.Join(dir, "node_modules", "expo"))
+}
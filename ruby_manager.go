@@ -0,0 +1,523 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-utils/v2/env"
+	"github.com/bitrise-io/go-utils/v2/log"
+)
+
+const (
+	rbenvRubyManagerName  = "rbenv"
+	asdfRubyManagerName   = "asdf"
+	miseRubyManagerName   = "mise"
+	chrubyRubyManagerName = "chruby"
+	rvmRubyManagerName    = "rvm"
+	systemRubyManagerName = "system"
+)
+
+var errRubyInstallNotSupported = errors.New("installing Ruby versions is not supported")
+
+// RubyVersionRequest is the Ruby version requested by the project and where it was requested from.
+type RubyVersionRequest struct {
+	Version string
+	Source  string
+}
+
+// SourceName returns the name of the version file or environment variable the version was requested by.
+func (r RubyVersionRequest) SourceName() string {
+	if filepath.IsAbs(r.Source) {
+		return filepath.Base(r.Source)
+	}
+	return r.Source
+}
+
+// RubyManager abstracts the Ruby version manager used on the machine.
+type RubyManager interface {
+	// Name returns the name of the version manager.
+	Name() string
+	// RequestedVersion returns the Ruby version requested for the given directory.
+	// An empty version is returned if no version is requested.
+	RequestedVersion(workdir string) (RubyVersionRequest, error)
+	// InstalledVersions returns the installed Ruby versions.
+	InstalledVersions() ([]string, error)
+	// Install installs the given Ruby version.
+	Install(version string) error
+	// EffectiveVersion returns the Ruby version used in the given directory.
+	EffectiveVersion(workdir string) (string, error)
+}
+
+// detectRubyManager returns the RubyManager matching the Ruby found in the PATH.
+func detectRubyManager(cmdLocator env.CommandLocator, envRepository env.Repository, cmdFactory command.Factory, logger log.Logger) RubyManager {
+	name := detectRubyManagerName(cmdLocator, envRepository)
+	return newRubyManager(name, cmdFactory, envRepository, logger)
+}
+
+func detectRubyManagerName(cmdLocator env.CommandLocator, envRepository env.Repository) string {
+	rubyPth, err := cmdLocator.LookPath("ruby")
+	if err != nil {
+		return systemRubyManagerName
+	}
+
+	isAvailable := func(name string) bool {
+		_, err := cmdLocator.LookPath(name)
+		return err == nil
+	}
+
+	switch {
+	case rubyPth == "/usr/bin/ruby", rubyPth == "/usr/local/bin/ruby", rubyPth == "/usr/local/opt/ruby/bin/ruby", strings.HasPrefix(rubyPth, "/opt/homebrew/"):
+		return systemRubyManagerName
+	case strings.Contains(rubyPth, "/.asdf/") && isAvailable("asdf"):
+		return asdfRubyManagerName
+	case (strings.Contains(rubyPth, "/mise/") || isInMiseDataDir(rubyPth, envRepository)) && isAvailable("mise"):
+		return miseRubyManagerName
+	case strings.Contains(rubyPth, "/.rbenv/") && isAvailable("rbenv"):
+		return rbenvRubyManagerName
+	case strings.Contains(rubyPth, "/.rvm/") && isAvailable("rvm"):
+		return rvmRubyManagerName
+	case envRepository.Get("RUBY_ROOT") != "" && strings.HasPrefix(rubyPth, envRepository.Get("RUBY_ROOT")):
+		return chrubyRubyManagerName
+	case isAvailable("rvm"):
+		return rvmRubyManagerName
+	case isAvailable("rbenv"):
+		return rbenvRubyManagerName
+	}
+
+	return systemRubyManagerName
+}
+
+func isInMiseDataDir(pth string, envRepository env.Repository) bool {
+	dataDir := envRepository.Get("MISE_DATA_DIR")
+	return dataDir != "" && strings.HasPrefix(pth, filepath.Clean(dataDir)+string(filepath.Separator))
+}
+
+func newRubyManager(name string, cmdFactory command.Factory, envRepository env.Repository, logger log.Logger) RubyManager {
+	base := rubyManagerBase{
+		cmdFactory:    cmdFactory,
+		envRepository: envRepository,
+		logger:        logger,
+	}
+
+	switch name {
+	case rbenvRubyManagerName:
+		return rbenvRubyManager{base}
+	case asdfRubyManagerName:
+		return asdfRubyManager{base}
+	case miseRubyManagerName:
+		return miseRubyManager{base}
+	case chrubyRubyManagerName:
+		return chrubyRubyManager{base}
+	case rvmRubyManagerName:
+		return rvmRubyManager{base}
+	default:
+		return systemRubyManager{base}
+	}
+}
+
+type rubyManagerBase struct {
+	cmdFactory    command.Factory
+	envRepository env.Repository
+	logger        log.Logger
+}
+
+func (m rubyManagerBase) output(name string, args []string, dir string) (string, error) {
+	cmd := m.cmdFactory.Create(name, args, &command.Opts{Dir: dir})
+	out, err := cmd.RunAndReturnTrimmedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", out, err)
+	}
+	return out, nil
+}
+
+func (m rubyManagerBase) run(name string, args ...string) error {
+	cmd := m.cmdFactory.Create(name, args, &command.Opts{
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	})
+	m.logger.Donef("$ %s", cmd.PrintableCommandArgs())
+	return cmd.Run()
+}
+
+func (m rubyManagerBase) rubyVersion(workdir string) (string, error) {
+	return m.output("ruby", []string{"-e", "print RUBY_VERSION"}, workdir)
+}
+
+// rbenv
+
+type rbenvRubyManager struct {
+	rubyManagerBase
+}
+
+func (m rbenvRubyManager) Name() string {
+	return rbenvRubyManagerName
+}
+
+func (m rbenvRubyManager) RequestedVersion(workdir string) (RubyVersionRequest, error) {
+	return findRequestedRubyVersion(workdir, m.envRepository, "RBENV_VERSION", rubyVersionFile)
+}
+
+func (m rbenvRubyManager) InstalledVersions() ([]string, error) {
+	out, err := m.output("rbenv", []string{"versions", "--bare"}, "")
+	if err != nil {
+		return nil, err
+	}
+	return parseVersionList(out), nil
+}
+
+func (m rbenvRubyManager) Install(version string) error {
+	return m.run("rbenv", "install", version)
+}
+
+func (m rbenvRubyManager) EffectiveVersion(workdir string) (string, error) {
+	version, err := m.output("rbenv", []string{"version-name"}, workdir)
+	if err != nil {
+		// The selected version is not installed, rbenv falls back to the global version
+		return m.output("rbenv", []string{"global"}, "")
+	}
+	return version, nil
+}
+
+// asdf
+
+type asdfRubyManager struct {
+	rubyManagerBase
+}
+
+func (m asdfRubyManager) Name() string {
+	return asdfRubyManagerName
+}
+
+func (m asdfRubyManager) RequestedVersion(workdir string) (RubyVersionRequest, error) {
+	return findRequestedRubyVersion(workdir, m.envRepository, "ASDF_RUBY_VERSION", toolVersionsFile, rubyVersionFile)
+}
+
+func (m asdfRubyManager) InstalledVersions() ([]string, error) {
+	out, err := m.output("asdf", []string{"list", "ruby"}, "")
+	if err != nil {
+		return nil, err
+	}
+	return parseVersionList(out), nil
+}
+
+func (m asdfRubyManager) Install(version string) error {
+	return m.run("asdf", "install", "ruby", version)
+}
+
+func (m asdfRubyManager) EffectiveVersion(workdir string) (string, error) {
+	return m.rubyVersion(workdir)
+}
+
+// mise
+
+type miseRubyManager struct {
+	rubyManagerBase
+}
+
+func (m miseRubyManager) Name() string {
+	return miseRubyManagerName
+}
+
+func (m miseRubyManager) RequestedVersion(workdir string) (RubyVersionRequest, error) {
+	files := append(miseConfigFiles(m.envRepository.Get("MISE_ENV")), toolVersionsFile, rubyVersionFile)
+	return findRequestedRubyVersion(workdir, m.envRepository, "MISE_RUBY_VERSION", files...)
+}
+
+func (m miseRubyManager) InstalledVersions() ([]string, error) {
+	out, err := m.output("mise", []string{"ls", "--installed", "--json", "ruby"}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var installed []struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(out), &installed); err != nil {
+		return nil, fmt.Errorf("failed to parse installed Ruby versions: %w", err)
+	}
+
+	var versions []string
+	for _, i := range installed {
+		versions = append(versions, i.Version)
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+func (m miseRubyManager) Install(version string) error {
+	return m.run("mise", "install", "ruby@"+version)
+}
+
+func (m miseRubyManager) EffectiveVersion(workdir string) (string, error) {
+	return m.rubyVersion(workdir)
+}
+
+// chruby
+
+type chrubyRubyManager struct {
+	rubyManagerBase
+}
+
+func (m chrubyRubyManager) Name() string {
+	return chrubyRubyManagerName
+}
+
+func (m chrubyRubyManager) RequestedVersion(workdir string) (RubyVersionRequest, error) {
+	return findRequestedRubyVersion(workdir, m.envRepository, "", rubyVersionFile)
+}
+
+func (m chrubyRubyManager) InstalledVersions() ([]string, error) {
+	// chruby is a shell function, the installed rubies are looked up in its default directories
+	var versions []string
+	for _, dir := range []string{"/opt/rubies", filepath.Join(m.envRepository.Get("HOME"), ".rubies")} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				versions = append(versions, entry.Name())
+			}
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+func (m chrubyRubyManager) Install(string) error {
+	// chruby is a shell function, a Ruby installed with ruby-install could not be activated for the Step's commands,
+	// pod would still run on the previous Ruby.
+	return fmt.Errorf("chruby can not activate a new Ruby version outside of the shell: %w", errRubyInstallNotSupported)
+}
+
+func (m chrubyRubyManager) EffectiveVersion(workdir string) (string, error) {
+	return m.rubyVersion(workdir)
+}
+
+// rvm
+
+type rvmRubyManager struct {
+	rubyManagerBase
+}
+
+func (m rvmRubyManager) Name() string {
+	return rvmRubyManagerName
+}
+
+func (m rvmRubyManager) RequestedVersion(workdir string) (RubyVersionRequest, error) {
+	return findRequestedRubyVersion(workdir, m.envRepository, "", rubyVersionFile)
+}
+
+func (m rvmRubyManager) InstalledVersions() ([]string, error) {
+	out, err := m.output("rvm", []string{"list", "strings"}, "")
+	if err != nil {
+		return nil, err
+	}
+	return parseVersionList(out), nil
+}
+
+func (m rvmRubyManager) Install(version string) error {
+	return m.run("rvm", "install", version)
+}
+
+func (m rvmRubyManager) EffectiveVersion(workdir string) (string, error) {
+	return m.rubyVersion(workdir)
+}
+
+// system
+
+type systemRubyManager struct {
+	rubyManagerBase
+}
+
+func (m systemRubyManager) Name() string {
+	return systemRubyManagerName
+}
+
+func (m systemRubyManager) RequestedVersion(workdir string) (RubyVersionRequest, error) {
+	return findRequestedRubyVersion(workdir, m.envRepository, "", rubyVersionFile)
+}
+
+func (m systemRubyManager) InstalledVersions() ([]string, error) {
+	effectiveVersion, err := m.rubyVersion("")
+	if err != nil {
+		return nil, err
+	}
+	return []string{effectiveVersion}, nil
+}
+
+func (m systemRubyManager) Install(string) error {
+	return errRubyInstallNotSupported
+}
+
+func (m systemRubyManager) EffectiveVersion(workdir string) (string, error) {
+	return m.rubyVersion(workdir)
+}
+
+// Requested Ruby version lookup
+
+type rubyVersionFileParser struct {
+	name  string
+	parse func(content string) string
+}
+
+var (
+	rubyVersionFile  = rubyVersionFileParser{name: ".ruby-version", parse: parseRubyVersionFileContent}
+	toolVersionsFile = rubyVersionFileParser{name: ".tool-versions", parse: parseToolVersionsContent}
+)
+
+// miseConfigFiles returns the mise config files of a directory in precedence order.
+// src: https://mise.jdx.dev/configuration.html
+func miseConfigFiles(miseEnv string) []rubyVersionFileParser {
+	names := []string{"mise.local.toml", ".mise.local.toml"}
+	if miseEnv != "" {
+		names = append(names, fmt.Sprintf("mise.%s.toml", miseEnv), fmt.Sprintf(".mise.%s.toml", miseEnv))
+	}
+	names = append(names, "mise.toml", ".mise.toml", "mise/config.toml", ".config/mise.toml", ".config/mise/config.toml")
+
+	var files []rubyVersionFileParser
+	for _, name := range names {
+		files = append(files, rubyVersionFileParser{name: name, parse: parseMiseTomlContent})
+	}
+	return files
+}
+
+// findRequestedRubyVersion returns the Ruby version set by the given environment variable or by the first version file
+// found in the workdir or in its parent directories.
+func findRequestedRubyVersion(workdir string, envRepository env.Repository, envKey string, files ...rubyVersionFileParser) (RubyVersionRequest, error) {
+	if envKey != "" {
+		if version := envRepository.Get(envKey); version != "" {
+			return RubyVersionRequest{Version: normalizeRubyVersion(version), Source: envKey}, nil
+		}
+	}
+
+	absWorkdir, err := filepath.Abs(workdir)
+	if err != nil {
+		return RubyVersionRequest{}, err
+	}
+
+	for dir := absWorkdir; ; dir = filepath.Dir(dir) {
+		for _, file := range files {
+			pth := filepath.Join(dir, file.name)
+			content, err := os.ReadFile(pth)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return RubyVersionRequest{}, err
+			}
+
+			if version := file.parse(string(content)); version != "" {
+				return RubyVersionRequest{Version: version, Source: pth}, nil
+			}
+		}
+
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	return RubyVersionRequest{}, nil
+}
+
+func parseRubyVersionFileContent(content string) string {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	return normalizeRubyVersion(lines[0])
+}
+
+func parseToolVersionsContent(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(strings.SplitN(line, "#", 2)[0])
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "ruby" {
+			return normalizeRubyVersion(fields[1])
+		}
+	}
+	return ""
+}
+
+var (
+	tomlSectionRegexp   = regexp.MustCompile(`^\[(.+)\]$`)
+	miseRubyToolRegexp  = regexp.MustCompile(`^["']?ruby["']?\s*=\s*(.+)$`)
+	tomlStringRegexp    = regexp.MustCompile(`["']([^"']+)["']`)
+	tomlVersionKeyRegex = regexp.MustCompile(`version\s*=\s*["']([^"']+)["']`)
+)
+
+func parseMiseTomlContent(content string) string {
+	inToolsSection := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if match := tomlSectionRegexp.FindStringSubmatch(line); match != nil {
+			inToolsSection = strings.TrimSpace(match[1]) == "tools"
+			continue
+		}
+		if !inToolsSection {
+			continue
+		}
+
+		match := miseRubyToolRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		value := strings.TrimSpace(match[1])
+		// ruby = { version = "3.2.0" }
+		if strings.HasPrefix(value, "{") {
+			if m := tomlVersionKeyRegex.FindStringSubmatch(value); m != nil {
+				return normalizeRubyVersion(m[1])
+			}
+			return ""
+		}
+		// ruby = "3.2.0" or ruby = ["3.2.0", "3.1.0"]
+		if m := tomlStringRegexp.FindStringSubmatch(value); m != nil {
+			return normalizeRubyVersion(m[1])
+		}
+	}
+	return ""
+}
+
+func normalizeRubyVersion(version string) string {
+	return strings.TrimPrefix(strings.TrimSpace(version), "ruby-")
+}
+
+// parseVersionList parses the version list printed by the version managers, like:
+//
+//	  2.7.8
+//	* 3.2.0 (set by /Users/vagrant/.rbenv/version)
+//	ruby-3.1.4 [ x86_64 ]
+func parseVersionList(out string) []string {
+	var versions []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "*"))
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		versions = append(versions, fields[0])
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func isRubyVersionInList(version string, versions []string) bool {
+	for _, v := range versions {
+		if normalizeRubyVersion(v) == normalizeRubyVersion(version) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bitrise-steplib/steps-cocoapods-install/mocks"
+
+	"github.com/bitrise-io/go-utils/v2/env"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCommandLocator map[string]string
+
+func (l fakeCommandLocator) LookPath(file string) (string, error) {
+	if pth, ok := l[file]; ok {
+		return pth, nil
+	}
+	return "", errors.New("executable file not found in $PATH")
+}
+
+func Test_GivenRubyInPath_WhenDetectingRubyManager_ThenReturnsExpectedManager(t *testing.T) {
+	tests := []struct {
+		name       string
+		cmdLocator fakeCommandLocator
+		rubyRoot   string
+		miseData   string
+		want       string
+	}{
+		{
+			name:       "system Ruby",
+			cmdLocator: fakeCommandLocator{"ruby": "/usr/bin/ruby", "rbenv": "/usr/local/bin/rbenv"},
+			want:       systemRubyManagerName,
+		},
+		{
+			name:       "Homebrew Ruby",
+			cmdLocator: fakeCommandLocator{"ruby": "/opt/homebrew/opt/ruby/bin/ruby"},
+			want:       systemRubyManagerName,
+		},
+		{
+			name:       "rbenv shim",
+			cmdLocator: fakeCommandLocator{"ruby": "/Users/vagrant/.rbenv/shims/ruby", "rbenv": "/usr/local/bin/rbenv"},
+			want:       rbenvRubyManagerName,
+		},
+		{
+			name:       "asdf shim",
+			cmdLocator: fakeCommandLocator{"ruby": "/Users/vagrant/.asdf/shims/ruby", "asdf": "/usr/local/bin/asdf", "rbenv": "/usr/local/bin/rbenv"},
+			want:       asdfRubyManagerName,
+		},
+		{
+			name:       "mise shim",
+			cmdLocator: fakeCommandLocator{"ruby": "/Users/vagrant/.local/share/mise/shims/ruby", "mise": "/usr/local/bin/mise"},
+			want:       miseRubyManagerName,
+		},
+		{
+			name:       "mise activated with custom data directory",
+			cmdLocator: fakeCommandLocator{"ruby": "/opt/mise-data/installs/ruby/3.3.0/bin/ruby", "mise": "/usr/local/bin/mise", "rbenv": "/usr/local/bin/rbenv"},
+			miseData:   "/opt/mise-data",
+			want:       miseRubyManagerName,
+		},
+		{
+			name:       "rvm Ruby",
+			cmdLocator: fakeCommandLocator{"ruby": "/Users/vagrant/.rvm/rubies/ruby-3.2.0/bin/ruby", "rvm": "/Users/vagrant/.rvm/bin/rvm"},
+			want:       rvmRubyManagerName,
+		},
+		{
+			name:       "chruby Ruby",
+			cmdLocator: fakeCommandLocator{"ruby": "/opt/rubies/ruby-3.2.0/bin/ruby"},
+			rubyRoot:   "/opt/rubies/ruby-3.2.0",
+			want:       chrubyRubyManagerName,
+		},
+		{
+			name:       "no Ruby",
+			cmdLocator: fakeCommandLocator{},
+			want:       systemRubyManagerName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RUBY_ROOT", tt.rubyRoot)
+			t.Setenv("MISE_DATA_DIR", tt.miseData)
+
+			got := detectRubyManagerName(tt.cmdLocator, env.NewRepository())
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_GivenVersionFiles_WhenFindingRequestedRubyVersion_ThenReturnsClosestVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]string
+		envValue   string
+		miseEnv    string
+		workdir    string
+		manager    string
+		want       string
+		wantSource string
+	}{
+		{
+			name:       "rbenv: .ruby-version in workdir",
+			files:      map[string]string{"ios/.ruby-version": "3.2.0\n", ".ruby-version": "2.7.8"},
+			workdir:    "ios",
+			manager:    rbenvRubyManagerName,
+			want:       "3.2.0",
+			wantSource: "ios/.ruby-version",
+		},
+		{
+			name:       "rbenv: .ruby-version in parent directory",
+			files:      map[string]string{".ruby-version": "ruby-3.1.4"},
+			workdir:    "ios",
+			manager:    rbenvRubyManagerName,
+			want:       "3.1.4",
+			wantSource: ".ruby-version",
+		},
+		{
+			name:       "rbenv: RBENV_VERSION overrides files",
+			files:      map[string]string{".ruby-version": "3.1.4"},
+			envValue:   "3.3.0",
+			workdir:    "ios",
+			manager:    rbenvRubyManagerName,
+			want:       "3.3.0",
+			wantSource: "RBENV_VERSION",
+		},
+		{
+			name:       "asdf: .tool-versions is preferred over .ruby-version",
+			files:      map[string]string{"ios/.tool-versions": "nodejs 20.0.0\nruby 3.2.2\n", "ios/.ruby-version": "3.1.4"},
+			workdir:    "ios",
+			manager:    asdfRubyManagerName,
+			want:       "3.2.2",
+			wantSource: "ios/.tool-versions",
+		},
+		{
+			name:       "mise: closer .ruby-version is preferred over mise.toml in parent directory",
+			files:      map[string]string{"mise.toml": "[env]\nFOO = \"bar\"\n\n[tools]\nnode = \"20\"\nruby = \"3.3.1\"\n", "ios/.ruby-version": "3.1.4"},
+			workdir:    "ios",
+			manager:    miseRubyManagerName,
+			want:       "3.1.4",
+			wantSource: "ios/.ruby-version",
+		},
+		{
+			name:       "mise: mise.toml in workdir",
+			files:      map[string]string{"ios/mise.toml": "[tools]\nruby = \"3.3.1\"\n", "ios/.ruby-version": "3.1.4"},
+			workdir:    "ios",
+			manager:    miseRubyManagerName,
+			want:       "3.3.1",
+			wantSource: "ios/mise.toml",
+		},
+		{
+			name:       "mise: mise.local.toml is preferred over mise.toml",
+			files:      map[string]string{"ios/mise.toml": "[tools]\nruby = \"3.3.1\"\n", "ios/mise.local.toml": "[tools]\nruby = \"3.4.0\"\n"},
+			workdir:    "ios",
+			manager:    miseRubyManagerName,
+			want:       "3.4.0",
+			wantSource: "ios/mise.local.toml",
+		},
+		{
+			name:       "mise: environment specific config",
+			files:      map[string]string{"ios/mise.toml": "[tools]\nruby = \"3.3.1\"\n", "ios/mise.ci.toml": "[tools]\nruby = \"3.2.4\"\n"},
+			miseEnv:    "ci",
+			workdir:    "ios",
+			manager:    miseRubyManagerName,
+			want:       "3.2.4",
+			wantSource: "ios/mise.ci.toml",
+		},
+		{
+			name:       "mise: config in .config directory",
+			files:      map[string]string{".config/mise/config.toml": "[tools]\nruby = \"3.3.1\"\n"},
+			workdir:    "ios",
+			manager:    miseRubyManagerName,
+			want:       "3.3.1",
+			wantSource: ".config/mise/config.toml",
+		},
+		{
+			name:    "no version requested",
+			files:   map[string]string{".tool-versions": "nodejs 20.0.0\n"},
+			workdir: "ios",
+			manager: asdfRubyManagerName,
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			for name, content := range tt.files {
+				pth := filepath.Join(tmpDir, name)
+				require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+				require.NoError(t, os.WriteFile(pth, []byte(content), 0644))
+			}
+			workdir := filepath.Join(tmpDir, tt.workdir)
+			require.NoError(t, os.MkdirAll(workdir, 0755))
+
+			for _, key := range []string{"RBENV_VERSION", "ASDF_RUBY_VERSION", "MISE_RUBY_VERSION"} {
+				t.Setenv(key, "")
+			}
+			t.Setenv("MISE_ENV", tt.miseEnv)
+			if tt.envValue != "" {
+				t.Setenv("RBENV_VERSION", tt.envValue)
+			}
+
+			manager := newRubyManager(tt.manager, new(mocks.CommandFactory), env.NewRepository(), new(mocks.Logger))
+			got, err := manager.RequestedVersion(workdir)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got.Version)
+
+			wantSource := tt.wantSource
+			if wantSource != "" && wantSource != "RBENV_VERSION" {
+				wantSource = filepath.Join(tmpDir, wantSource)
+			}
+			require.Equal(t, wantSource, got.Source)
+		})
+	}
+}
+
+func TestParseMiseTomlContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "string version", content: "[tools]\nruby = '3.2.0'\n", want: "3.2.0"},
+		{name: "version list", content: "[tools]\nruby = [\"3.2.0\", \"3.1.4\"]\n", want: "3.2.0"},
+		{name: "tool options", content: "[tools]\nruby = { version = \"3.2.0\", virtualenv = \"x\" }\n", want: "3.2.0"},
+		{name: "quoted key", content: "[tools]\n\"ruby\" = \"3.2.0\" # comment\n", want: "3.2.0"},
+		{name: "ruby outside of tools section", content: "[env]\nruby = \"3.2.0\"\n", want: ""},
+		{name: "no ruby", content: "[tools]\nnode = \"20\"\n", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, parseMiseTomlContent(tt.content))
+		})
+	}
+}
+
+func Test_GivenVersionManagerOutput_WhenListingInstalledRubies_ThenReturnsVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		manager string
+		cmd     []string
+		out     string
+		want    []string
+	}{
+		{
+			name:    "rbenv",
+			manager: rbenvRubyManagerName,
+			cmd:     []string{"rbenv", "versions", "--bare"},
+			out:     "2.7.8\n3.2.0",
+			want:    []string{"2.7.8", "3.2.0"},
+		},
+		{
+			name:    "asdf",
+			manager: asdfRubyManagerName,
+			cmd:     []string{"asdf", "list", "ruby"},
+			out:     "  3.1.4\n *3.2.0",
+			want:    []string{"3.1.4", "3.2.0"},
+		},
+		{
+			name:    "mise",
+			manager: miseRubyManagerName,
+			cmd:     []string{"mise", "ls", "--installed", "--json", "ruby"},
+			out:     `[{"version": "3.3.0", "installed": true}, {"version": "3.2.0", "installed": true}]`,
+			want:    []string{"3.2.0", "3.3.0"},
+		},
+		{
+			name:    "rvm",
+			manager: rvmRubyManagerName,
+			cmd:     []string{"rvm", "list", "strings"},
+			out:     "ruby-3.1.4\nruby-3.2.0",
+			want:    []string{"ruby-3.1.4", "ruby-3.2.0"},
+		},
+		{
+			name:    "system Ruby",
+			manager: systemRubyManagerName,
+			cmd:     []string{"ruby", "-e", "print RUBY_VERSION"},
+			out:     "2.6.10",
+			want:    []string{"2.6.10"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := new(mocks.Command)
+			cmd.On("RunAndReturnTrimmedOutput").Return(tt.out, nil)
+
+			cmdFactory := new(mocks.CommandFactory)
+			cmdFactory.On("Create", tt.cmd[0], tt.cmd[1:], mock.Anything).Return(cmd)
+
+			manager := newRubyManager(tt.manager, cmdFactory, env.NewRepository(), new(mocks.Logger))
+			got, err := manager.InstalledVersions()
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+			cmdFactory.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_GivenRubyManager_WhenInstallingRuby_ThenRunsExpectedCommand(t *testing.T) {
+	tests := []struct {
+		manager string
+		wantCmd []string
+	}{
+		{manager: rbenvRubyManagerName, wantCmd: []string{"rbenv", "install", "3.2.0"}},
+		{manager: asdfRubyManagerName, wantCmd: []string{"asdf", "install", "ruby", "3.2.0"}},
+		{manager: miseRubyManagerName, wantCmd: []string{"mise", "install", "ruby@3.2.0"}},
+		{manager: rvmRubyManagerName, wantCmd: []string{"rvm", "install", "3.2.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.manager, func(t *testing.T) {
+			cmd := new(mocks.Command)
+			cmd.On("PrintableCommandArgs").Return("")
+			cmd.On("Run").Return(nil)
+
+			cmdFactory := new(mocks.CommandFactory)
+			cmdFactory.On("Create", tt.wantCmd[0], tt.wantCmd[1:], mock.Anything).Return(cmd)
+
+			logger := new(mocks.Logger)
+			logger.On("Donef", mock.Anything, mock.Anything)
+
+			manager := newRubyManager(tt.manager, cmdFactory, env.NewRepository(), logger)
+			require.NoError(t, manager.Install("3.2.0"))
+			cmdFactory.AssertExpectations(t)
+			cmd.AssertExpectations(t)
+		})
+	}
+
+	t.Run(systemRubyManagerName, func(t *testing.T) {
+		manager := newRubyManager(systemRubyManagerName, new(mocks.CommandFactory), env.NewRepository(), new(mocks.Logger))
+		require.Equal(t, errRubyInstallNotSupported, manager.Install("3.2.0"))
+	})
+
+	t.Run(chrubyRubyManagerName, func(t *testing.T) {
+		cmdFactory := new(mocks.CommandFactory)
+		manager := newRubyManager(chrubyRubyManagerName, cmdFactory, env.NewRepository(), new(mocks.Logger))
+		require.ErrorIs(t, manager.Install("3.2.0"), errRubyInstallNotSupported)
+		cmdFactory.AssertNotCalled(t, "Create", "ruby-install", mock.Anything, mock.Anything)
+	})
+}
+
+func Test_GivenRbenvSelectedVersionNotInstalled_WhenCheckingEffectiveVersion_ThenReturnsGlobalVersion(t *testing.T) {
+	versionNameCmd := new(mocks.Command)
+	versionNameCmd.On("RunAndReturnTrimmedOutput").Return("", errors.New("rbenv: version `3.3.0' is not installed"))
+
+	globalCmd := new(mocks.Command)
+	globalCmd.On("RunAndReturnTrimmedOutput").Return("3.2.0", nil)
+
+	cmdFactory := new(mocks.CommandFactory)
+	cmdFactory.On("Create", "rbenv", []string{"version-name"}, mock.Anything).Return(versionNameCmd)
+	cmdFactory.On("Create", "rbenv", []string{"global"}, mock.Anything).Return(globalCmd)
+
+	manager := newRubyManager(rbenvRubyManagerName, cmdFactory, env.NewRepository(), new(mocks.Logger))
+	got, err := manager.EffectiveVersion("")
+	require.NoError(t, err)
+	require.Equal(t, "3.2.0", got)
+}
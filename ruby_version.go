@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/v2/env"
+	"github.com/bitrise-io/go-utils/v2/log"
+)
+
+const (
+	rubyInstallPolicyInstallOrFail = "install-or-fail"
+	rubyInstallPolicyInstallOrWarn = "install-or-warn"
+	rubyInstallPolicyNeverInstall  = "never-install"
+)
+
+// RubyVersionSelection is the outcome of checking the Ruby version requested by the project.
+type RubyVersionSelection struct {
+	Request          RubyVersionRequest
+	Installed        bool
+	EffectiveVersion string
+}
+
+// RubyVersionSelector makes sure the Ruby version requested by the project is installed.
+type RubyVersionSelector struct {
+	rubyManager   RubyManager
+	envRepository env.Repository
+	logger        log.Logger
+}
+
+// NewRubyVersionSelector ...
+func NewRubyVersionSelector(rubyManager RubyManager, envRepository env.Repository, logger log.Logger) RubyVersionSelector {
+	return RubyVersionSelector{
+		rubyManager:   rubyManager,
+		envRepository: envRepository,
+		logger:        logger,
+	}
+}
+
+// SelectRubyVersion checks the Ruby version requested for the workdir and installs it if missing, according to the install policy.
+// Missing versions are only installed in CI environment, where the Ruby was installed via a version manager for the virtual machine.
+func (s RubyVersionSelector) SelectRubyVersion(workdir, installPolicy string) (RubyVersionSelection, error) {
+	request, err := s.rubyManager.RequestedVersion(workdir)
+	if err != nil {
+		s.logger.Warnf("Failed to determine the requested Ruby version: %s", err)
+	}
+
+	selection := RubyVersionSelection{Request: request}
+
+	if request.Version == "" {
+		s.logger.Printf("No Ruby version requested")
+	} else {
+		s.logger.Printf("Requested Ruby version: %s (set by %s)", request.Version, request.Source)
+
+		isCI := s.envRepository.Get("CI") == "true"
+		if s.rubyManager.Name() == miseRubyManagerName && isCI {
+			s.trustMiseConfig(request)
+		}
+
+		installedVersions, err := s.rubyManager.InstalledVersions()
+		if err != nil {
+			s.logger.Warnf("Failed to check if selected ruby is installed: %s", err)
+		}
+		selection.Installed = isRubyVersionInList(request.Version, installedVersions)
+
+		if selection.Installed {
+			s.logger.Donef("Ruby %s is installed", request.Version)
+		} else if installPolicy == rubyInstallPolicyNeverInstall || !isCI {
+			s.logger.Warnf("Ruby %s is not installed, using the default Ruby version", request.Version)
+		} else {
+			s.logger.Warnf("Ruby %s is not installed", request.Version)
+			s.logger.Printf("")
+			s.logger.Infof("Installing missing Ruby version")
+
+			if err := s.rubyManager.Install(request.Version); err != nil {
+				installErr := s.rubyInstallError(request, installedVersions, err)
+				if installPolicy != rubyInstallPolicyInstallOrWarn {
+					return selection, installErr
+				}
+				s.logger.Warnf("%s", installErr)
+			} else {
+				selection.Installed = true
+			}
+		}
+	}
+
+	effectiveVersion, err := s.rubyManager.EffectiveVersion(workdir)
+	if err != nil {
+		s.logger.Warnf("Failed to check the effective Ruby version: %s", err)
+	} else {
+		s.logger.Donef("Effective Ruby version: %s", effectiveVersion)
+	}
+	selection.EffectiveVersion = effectiveVersion
+
+	return selection, nil
+}
+
+// trustMiseConfig makes mise read the project's config file, both when installing Ruby and when running the Ruby shims.
+// The config file itself is trusted, as nested configs (.config/mise/config.toml, .mise/config.toml) are not in the project directory.
+// Versions requested by .ruby-version or .tool-versions need no trust, only the mise config files (mise.toml, .mise.toml, ...) do.
+func (s RubyVersionSelector) trustMiseConfig(request RubyVersionRequest) {
+	if !filepath.IsAbs(request.Source) || filepath.Ext(request.Source) != ".toml" {
+		return
+	}
+
+	trustedPaths := request.Source
+	if existing := s.envRepository.Get("MISE_TRUSTED_CONFIG_PATHS"); existing != "" {
+		trustedPaths = existing + string(os.PathListSeparator) + trustedPaths
+	}
+	if err := s.envRepository.Set("MISE_TRUSTED_CONFIG_PATHS", trustedPaths); err != nil {
+		s.logger.Warnf("Failed to set MISE_TRUSTED_CONFIG_PATHS: %s", err)
+	}
+}
+
+func (s RubyVersionSelector) rubyInstallError(request RubyVersionRequest, installedVersions []string, err error) error {
+	available := "none"
+	if len(installedVersions) > 0 {
+		available = strings.Join(installedVersions, ", ")
+	}
+
+	return fmt.Errorf(`failed to install Ruby %s: %w
+Requested by: %s
+Available Ruby versions (%s): %s
+Install the requested version, or change the version in %s to one of the available versions.
+Set the ruby_install_policy input to %s to continue with the default Ruby version.`,
+		request.Version, err, request.Source, s.rubyManager.Name(), available, request.SourceName(), rubyInstallPolicyInstallOrWarn)
+}
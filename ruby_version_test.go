@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"bitrise-steplib/steps-cocoapods-install/mocks"
+
+	"github.com/bitrise-io/go-utils/v2/env"
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRubyManager struct {
+	name              string
+	request           RubyVersionRequest
+	installedVersions []string
+	installErr        error
+	effectiveVersion  string
+
+	installed []string
+}
+
+func (m *fakeRubyManager) Name() string {
+	return m.name
+}
+
+func (m *fakeRubyManager) RequestedVersion(string) (RubyVersionRequest, error) {
+	return m.request, nil
+}
+
+func (m *fakeRubyManager) InstalledVersions() ([]string, error) {
+	return m.installedVersions, nil
+}
+
+func (m *fakeRubyManager) Install(version string) error {
+	m.installed = append(m.installed, version)
+	return m.installErr
+}
+
+func (m *fakeRubyManager) EffectiveVersion(string) (string, error) {
+	return m.effectiveVersion, nil
+}
+
+func Test_GivenRubyInstallPolicy_WhenSelectingRubyVersion_ThenFollowsPolicy(t *testing.T) {
+	request := RubyVersionRequest{Version: "3.3.0", Source: "/project/.ruby-version"}
+
+	tests := []struct {
+		name          string
+		manager       string
+		request       RubyVersionRequest
+		installed     []string
+		installErr    error
+		policy        string
+		ci            string
+		wantInstalled []string
+		wantSelection RubyVersionSelection
+		wantErr       string
+	}{
+		{
+			name:          "requested version is installed",
+			request:       request,
+			installed:     []string{"3.2.0", "3.3.0"},
+			policy:        rubyInstallPolicyInstallOrFail,
+			ci:            "true",
+			wantSelection: RubyVersionSelection{Request: request, Installed: true, EffectiveVersion: "3.2.0"},
+		},
+		{
+			name:          "no version requested",
+			policy:        rubyInstallPolicyInstallOrFail,
+			ci:            "true",
+			wantSelection: RubyVersionSelection{EffectiveVersion: "3.2.0"},
+		},
+		{
+			name:          "install-or-fail installs the missing version",
+			request:       request,
+			installed:     []string{"3.2.0"},
+			policy:        rubyInstallPolicyInstallOrFail,
+			ci:            "true",
+			wantInstalled: []string{"3.3.0"},
+			wantSelection: RubyVersionSelection{Request: request, Installed: true, EffectiveVersion: "3.2.0"},
+		},
+		{
+			name:          "install-or-fail fails if the install fails",
+			request:       request,
+			installed:     []string{"2.7.8", "3.2.0"},
+			installErr:    errors.New("BUILD FAILED"),
+			policy:        rubyInstallPolicyInstallOrFail,
+			ci:            "true",
+			wantInstalled: []string{"3.3.0"},
+			wantErr: `failed to install Ruby 3.3.0: BUILD FAILED
+Requested by: /project/.ruby-version
+Available Ruby versions (rbenv): 2.7.8, 3.2.0
+Install the requested version, or change the version in .ruby-version to one of the available versions.
+Set the ruby_install_policy input to install-or-warn to continue with the default Ruby version.`,
+		},
+		{
+			name:          "install-or-warn continues if the install fails",
+			request:       request,
+			installed:     []string{"3.2.0"},
+			installErr:    errors.New("BUILD FAILED"),
+			policy:        rubyInstallPolicyInstallOrWarn,
+			ci:            "true",
+			wantInstalled: []string{"3.3.0"},
+			wantSelection: RubyVersionSelection{Request: request, Installed: false, EffectiveVersion: "3.2.0"},
+		},
+		{
+			name:          "install-or-fail fails if the Ruby manager can not install",
+			manager:       systemRubyManagerName,
+			request:       request,
+			installed:     []string{"3.2.0"},
+			installErr:    errRubyInstallNotSupported,
+			policy:        rubyInstallPolicyInstallOrFail,
+			ci:            "true",
+			wantInstalled: []string{"3.3.0"},
+			wantErr: `failed to install Ruby 3.3.0: installing Ruby versions is not supported
+Requested by: /project/.ruby-version
+Available Ruby versions (system): 3.2.0
+Install the requested version, or change the version in .ruby-version to one of the available versions.
+Set the ruby_install_policy input to install-or-warn to continue with the default Ruby version.`,
+		},
+		{
+			name:          "install-or-warn continues if the Ruby manager can not install",
+			manager:       systemRubyManagerName,
+			request:       request,
+			installed:     []string{"3.2.0"},
+			installErr:    errRubyInstallNotSupported,
+			policy:        rubyInstallPolicyInstallOrWarn,
+			ci:            "true",
+			wantInstalled: []string{"3.3.0"},
+			wantSelection: RubyVersionSelection{Request: request, Installed: false, EffectiveVersion: "3.2.0"},
+		},
+		{
+			name:          "never-install does not install the missing version",
+			request:       request,
+			installed:     []string{"3.2.0"},
+			policy:        rubyInstallPolicyNeverInstall,
+			ci:            "true",
+			wantSelection: RubyVersionSelection{Request: request, Installed: false, EffectiveVersion: "3.2.0"},
+		},
+		{
+			name:          "missing version is not installed outside of CI",
+			request:       request,
+			installed:     []string{"3.2.0"},
+			policy:        rubyInstallPolicyInstallOrFail,
+			ci:            "",
+			wantSelection: RubyVersionSelection{Request: request, Installed: false, EffectiveVersion: "3.2.0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CI", tt.ci)
+
+			manager := tt.manager
+			if manager == "" {
+				manager = rbenvRubyManagerName
+			}
+			rubyManager := &fakeRubyManager{
+				name:              manager,
+				request:           tt.request,
+				installedVersions: tt.installed,
+				installErr:        tt.installErr,
+				effectiveVersion:  "3.2.0",
+			}
+			selector := NewRubyVersionSelector(rubyManager, env.NewRepository(), log.NewLogger())
+
+			selection, err := selector.SelectRubyVersion("/project", tt.policy)
+			require.Equal(t, tt.wantInstalled, rubyManager.installed)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSelection, selection)
+		})
+	}
+}
+
+func Test_GivenChrubyWithMissingVersion_WhenSelectingRubyVersion_ThenKeepsTheActiveRuby(t *testing.T) {
+	// Given
+	t.Setenv("CI", "true")
+	t.Setenv("HOME", t.TempDir())
+	projectDir := createTestProject(t, map[string]string{".ruby-version": "3.3.0\n"})
+
+	rubyVersionCmd := new(mocks.Command)
+	rubyVersionCmd.On("RunAndReturnTrimmedOutput").Return("3.2.0", nil)
+	cmdFactory := new(mocks.CommandFactory)
+	cmdFactory.On("Create", "ruby", []string{"-e", "print RUBY_VERSION"}, mock.Anything).Return(rubyVersionCmd)
+
+	rubyManager := newRubyManager(chrubyRubyManagerName, cmdFactory, env.NewRepository(), log.NewLogger())
+	selector := NewRubyVersionSelector(rubyManager, env.NewRepository(), log.NewLogger())
+
+	// When
+	selection, err := selector.SelectRubyVersion(projectDir, rubyInstallPolicyInstallOrWarn)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, RubyVersionSelection{
+		Request:          RubyVersionRequest{Version: "3.3.0", Source: filepath.Join(projectDir, ".ruby-version")},
+		Installed:        false,
+		EffectiveVersion: "3.2.0",
+	}, selection)
+	cmdFactory.AssertNotCalled(t, "Create", "ruby-install", mock.Anything, mock.Anything)
+}
+
+func Test_GivenMiseConfig_WhenSelectingRubyVersionOnCI_ThenTrustsConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{name: "project config", source: "/project/mise.toml"},
+		{name: "nested config", source: "/project/.config/mise/config.toml"},
+		{name: "hidden directory config", source: "/project/.mise/config.toml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CI", "true")
+			t.Setenv("MISE_TRUSTED_CONFIG_PATHS", "/other")
+
+			rubyManager := &fakeRubyManager{
+				name:              miseRubyManagerName,
+				request:           RubyVersionRequest{Version: "3.3.0", Source: tt.source},
+				installedVersions: []string{"3.3.0"},
+			}
+			selector := NewRubyVersionSelector(rubyManager, env.NewRepository(), log.NewLogger())
+
+			_, err := selector.SelectRubyVersion("/project", rubyInstallPolicyInstallOrFail)
+			require.NoError(t, err)
+			require.Equal(t, "/other:"+tt.source, env.NewRepository().Get("MISE_TRUSTED_CONFIG_PATHS"))
+		})
+	}
+}
+
+func Test_GivenVersionFileWithMise_WhenSelectingRubyVersionOnCI_ThenTrustsNothing(t *testing.T) {
+	for _, source := range []string{"/project/.ruby-version", "/project/.tool-versions", "MISE_RUBY_VERSION"} {
+		t.Run(source, func(t *testing.T) {
+			t.Setenv("CI", "true")
+			t.Setenv("MISE_TRUSTED_CONFIG_PATHS", "/other")
+
+			rubyManager := &fakeRubyManager{
+				name:              miseRubyManagerName,
+				request:           RubyVersionRequest{Version: "3.3.0", Source: source},
+				installedVersions: []string{"3.3.0"},
+			}
+			selector := NewRubyVersionSelector(rubyManager, env.NewRepository(), log.NewLogger())
+
+			_, err := selector.SelectRubyVersion("/project", rubyInstallPolicyInstallOrFail)
+			require.NoError(t, err)
+			require.Equal(t, "/other", env.NewRepository().Get("MISE_TRUSTED_CONFIG_PATHS"))
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/analytics"
+)
+
+const (
+	errorCategoryInput           = "input"
+	errorCategoryVersionMismatch = "version_mismatch"
+	errorCategoryLockfile        = "lockfile"
+	errorCategoryRubyInstall     = "ruby_install"
+	errorCategoryGemInstall      = "gem_install"
+	errorCategoryPodInstall      = "pod_install"
+	errorCategoryTimeout         = "timeout"
+	errorCategoryReport          = "report"
+	errorCategoryLicense         = "license"
+	errorCategoryVulnerability   = "vulnerability"
+	errorCategoryChecksum        = "checksum"
+	errorCategoryGitCheckout     = "git_checkout"
+	errorCategorySourcePolicy    = "source_policy"
+	errorCategoryNodeModules     = "node_modules"
+	errorCategoryExport          = "export"
+	errorCategoryUnknown         = "unknown"
+)
+
+var podSources = []string{podSourceTrunk, podSourceGit, podSourcePath, podSourcePrivate}
+
+// categorizedError tells which part of the Step failed, for the end of run analytics event.
+type categorizedError struct {
+	category string
+	err      error
+}
+
+func (e *categorizedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *categorizedError) Unwrap() error {
+	return e.err
+}
+
+// withErrorCategory categorizes the error, unless it is nil or already categorized.
+func withErrorCategory(category string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var categorized *categorizedError
+	if errors.As(err, &categorized) {
+		return err
+	}
+	return &categorizedError{category: category, err: err}
+}
+
+// errorCategory returns the category of the error, timeouts are reported separately from the failing phase.
+func errorCategory(err error) string {
+	if err == nil {
+		return ""
+	}
+	if isCommandTimeoutError(err) {
+		return errorCategoryTimeout
+	}
+	var categorized *categorizedError
+	if errors.As(err, &categorized) {
+		return categorized.category
+	}
+	return errorCategoryUnknown
+}
+
+// RunReport collects the outcome of the Step run for the end of run analytics event.
+type RunReport struct {
+	startTime time.Time
+
+	Command           string
+	CocoapodsStrategy string
+	CocoapodsVersion  string
+	BundlerVersion    string
+	IsUsingSpecsRepo  bool
+	// RubyRequest is the requested Ruby version and the file it was read from, empty if no version was requested.
+	RubyRequest RubyVersionRequest
+	RubyVersion string
+	// RubyVersionChangeDuration is the time spent on selecting (and installing) the Ruby version.
+	RubyVersionChangeDuration time.Duration
+	// PodsManifestPresent is true if the Pods directory already contained a Manifest.lock before pod install,
+	// either restored by a cache pull Step or committed to the repository.
+	PodsManifestPresent bool
+	// GemInstallSkipped is true if neither CocoaPods nor the gem lockfile gems had to be installed.
+	GemInstallSkipped bool
+	RetryCount        int
+	PodCounts         map[string]int
+}
+
+// NewRunReport ...
+func NewRunReport() *RunReport {
+	return &RunReport{startTime: time.Now()}
+}
+
+// TrackRun sends a single analytics event about the whole Step run, both for successful and failed runs.
+func (s Step) TrackRun(err error) {
+	report := s.runReport
+
+	properties := analytics.Properties{
+		"step_execution_id":         s.envRepository.Get("BITRISE_STEP_EXECUTION_ID"),
+		"build_slug":                s.envRepository.Get("BITRISE_BUILD_SLUG"),
+		"command":                   report.Command,
+		"cocoapods_strategy":        report.CocoapodsStrategy,
+		"cocoapods_version":         report.CocoapodsVersion,
+		"bundler_version":           report.BundlerVersion,
+		"is_using_specs_repo":       report.IsUsingSpecsRepo,
+		"ruby_manager":              s.rubyManager.Name(),
+		"requested_ruby_version":    report.RubyRequest.Version,
+		"requested_ruby_source":     report.RubyRequest.SourceName(),
+		"ruby_version":              report.RubyVersion,
+		"version_change_duration_s": int64(report.RubyVersionChangeDuration.Seconds()),
+		"pods_manifest_present":     report.PodsManifestPresent,
+		"gem_install_skipped":       report.GemInstallSkipped,
+		"retry_count":               report.RetryCount,
+		"is_success":                err == nil,
+		"error_category":            errorCategory(err),
+		"duration_s":                int64(time.Since(report.startTime).Seconds()),
+	}
+	for _, source := range podSources {
+		properties[source+"_pods"] = report.PodCounts[source]
+	}
+	for key, value := range s.phaseTimer.AnalyticsProperties() {
+		properties[key] = value
+	}
+
+	s.tracker.Enqueue("step_cocoapods_install_finished", properties)
+}
+
+// isPodsManifestPresent tells if the Pods directory was already installed before the Step run.
+func (s Step) isPodsManifestPresent(podfileDir string) bool {
+	exists, err := s.pathChecker.IsPathExists(filepath.Join(podfileDir, "Pods", "Manifest.lock"))
+	if err != nil {
+		s.logger.Warnf("Failed to check Pods/Manifest.lock: %s", err)
+		return false
+	}
+	return exists
+}
+
+// podCountsBySource returns the number of locked pods per source type, nil if Podfile.lock is missing or invalid.
+func (s Step) podCountsBySource(podfileLockPath string) map[string]int {
+	if podfileLockPath == "" {
+		return nil
+	}
+	lock, err := readPodfileLock(podfileLockPath)
+	if err != nil {
+		s.logger.Warnf("Failed to read Podfile.lock: %s", err)
+		return nil
+	}
+	return lock.PodCountsBySource()
+}
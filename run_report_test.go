@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenStepErrors_WhenCategorizing_ThenReturnsCategory(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "no error", err: nil, want: ""},
+		{name: "uncategorized", err: errors.New("failed"), want: errorCategoryUnknown},
+		{name: "categorized", err: withErrorCategory(errorCategoryRubyInstall, errors.New("failed")), want: errorCategoryRubyInstall},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("step failed: %w", withErrorCategory(errorCategoryGemInstall, errors.New("failed"))),
+			want: errorCategoryGemInstall,
+		},
+		{
+			name: "first category wins",
+			err:  withErrorCategory(errorCategoryLockfile, withErrorCategory(errorCategoryVersionMismatch, errors.New("mismatch"))),
+			want: errorCategoryVersionMismatch,
+		},
+		{
+			name: "timeout",
+			err:  withErrorCategory(errorCategoryPodInstall, &CommandTimeoutError{Command: "pod install", Kind: timeoutKindTotal, Timeout: time.Minute}),
+			want: errorCategoryTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, errorCategory(tt.err))
+		})
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sbomFormatNone             = "none"
+	sbomFormatCycloneDX        = "cyclonedx"
+	sbomFormatCycloneDXAndSPDX = "cyclonedx+spdx"
+)
+
+const sbomToolName = "steps-cocoapods-install"
+
+func isSBOMEnabled(format string) bool {
+	return format == sbomFormatCycloneDX || format == sbomFormatCycloneDXAndSPDX
+}
+
+// SBOMPod is a root pod of the software bill of materials, the subspecs are merged into their root pod.
+type SBOMPod struct {
+	Name    string
+	Version string
+	// Source is the source type: trunk, git, path or private.
+	Source string
+	// SourceLocation is the git repository, the local path or the spec repo of the pod.
+	SourceLocation string
+	// SourceRevision is the locked commit (or tag) of a git pod, recorded in the CHECKOUT OPTIONS of Podfile.lock.
+	SourceRevision string
+	// Checksum is the SHA-1 checksum of the podspec, recorded in Podfile.lock.
+	Checksum     string
+	License      PodLicense
+	Dependencies []string
+}
+
+// sbomPods collects the root pods with their sources, checksums, licenses and root pod dependencies.
+func sbomPods(lock PodfileLock, licenses map[string]PodLicense) []SBOMPod {
+	graph := NewDependencyGraph(lock)
+
+	revisions := map[string]string{}
+	for _, gitPod := range lock.GitPods() {
+		revisions[gitPod.Name] = gitPod.LockedReference()
+	}
+
+	podsByName := map[string]*SBOMPod{}
+	var names []string
+	for _, pod := range graph.Pods {
+		sbomPod, ok := podsByName[pod.RootPod]
+		if !ok {
+			sbomPod = &SBOMPod{
+				Name:           pod.RootPod,
+				Version:        pod.Version,
+				Source:         pod.Source,
+				SourceLocation: podSourceLocation(lock, pod.RootPod),
+				SourceRevision: revisions[pod.RootPod],
+				Checksum:       lock.SpecChecksums[pod.RootPod],
+				License:        licenses[pod.RootPod],
+			}
+			podsByName[pod.RootPod] = sbomPod
+			names = append(names, pod.RootPod)
+		}
+
+		for _, dependency := range pod.Dependencies {
+			if root := podRootName(dependency); root != pod.RootPod {
+				sbomPod.Dependencies = appendUnique(sbomPod.Dependencies, root)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	pods := make([]SBOMPod, 0, len(names))
+	for _, name := range names {
+		sort.Strings(podsByName[name].Dependencies)
+		pods = append(pods, *podsByName[name])
+	}
+	return pods
+}
+
+func podSourceLocation(lock PodfileLock, rootName string) string {
+	if source, ok := lock.ExternalSources[rootName]; ok {
+		for _, key := range []string{":git", ":path", ":podspec", ":http"} {
+			if location, ok := source[key]; ok {
+				return location
+			}
+		}
+		return ""
+	}
+
+	for repo, pods := range lock.SpecRepos {
+		for _, pod := range pods {
+			if pod == rootName {
+				if repo == "trunk" {
+					return "https://cdn.cocoapods.org/"
+				}
+				return repo
+			}
+		}
+	}
+	return ""
+}
+
+func podPURL(pod SBOMPod) string {
+	return fmt.Sprintf("pkg:cocoapods/%s@%s", url.PathEscape(pod.Name), url.PathEscape(pod.Version))
+}
+
+// CycloneDXBOM is a CycloneDX 1.5 JSON document.
+type CycloneDXBOM struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     cycloneDXMetadata     `json:"metadata"`
+	Components   []cycloneDXComponent  `json:"components"`
+	Dependencies []cycloneDXDependency `json:"dependencies"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string `json:"timestamp"`
+	Tools     struct {
+		Components []cycloneDXComponent `json:"components"`
+	} `json:"tools"`
+}
+
+type cycloneDXComponent struct {
+	Type               string                       `json:"type"`
+	BOMRef             string                       `json:"bom-ref,omitempty"`
+	Name               string                       `json:"name"`
+	Version            string                       `json:"version,omitempty"`
+	PURL               string                       `json:"purl,omitempty"`
+	Hashes             []cycloneDXHash              `json:"hashes,omitempty"`
+	Licenses           []cycloneDXLicenseChoice     `json:"licenses,omitempty"`
+	ExternalReferences []cycloneDXExternalReference `json:"externalReferences,omitempty"`
+	Properties         []cycloneDXProperty          `json:"properties,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDXLicenseChoice struct {
+	License cycloneDXLicense `json:"license"`
+}
+
+type cycloneDXLicense struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type cycloneDXExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// NewCycloneDXBOM ...
+func NewCycloneDXBOM(pods []SBOMPod, serialNumber string, timestamp time.Time) CycloneDXBOM {
+	bom := CycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + serialNumber,
+		Version:      1,
+		Components:   []cycloneDXComponent{},
+		Dependencies: []cycloneDXDependency{},
+	}
+	bom.Metadata.Timestamp = timestamp.UTC().Format(time.RFC3339)
+	bom.Metadata.Tools.Components = []cycloneDXComponent{{Type: "application", Name: sbomToolName}}
+
+	refs := map[string]string{}
+	for _, pod := range pods {
+		refs[pod.Name] = podPURL(pod)
+	}
+
+	for _, pod := range pods {
+		component := cycloneDXComponent{
+			Type:       "library",
+			BOMRef:     refs[pod.Name],
+			Name:       pod.Name,
+			Version:    pod.Version,
+			PURL:       refs[pod.Name],
+			Properties: []cycloneDXProperty{{Name: "cocoapods:source", Value: pod.Source}},
+		}
+		if pod.Checksum != "" {
+			component.Hashes = []cycloneDXHash{{Alg: "SHA-1", Content: pod.Checksum}}
+		}
+		if pod.License.SPDXID != "" {
+			component.Licenses = []cycloneDXLicenseChoice{{License: cycloneDXLicense{ID: pod.License.SPDXID}}}
+		} else if pod.License.Name != "" {
+			component.Licenses = []cycloneDXLicenseChoice{{License: cycloneDXLicense{Name: pod.License.Name}}}
+		}
+		switch pod.Source {
+		case podSourceGit:
+			vcsURL := pod.SourceLocation
+			if pod.SourceRevision != "" {
+				vcsURL += "#" + pod.SourceRevision
+				component.Properties = append(component.Properties, cycloneDXProperty{Name: "cocoapods:revision", Value: pod.SourceRevision})
+			}
+			component.ExternalReferences = []cycloneDXExternalReference{{Type: "vcs", URL: vcsURL}}
+		case podSourceTrunk, podSourcePrivate:
+			if pod.SourceLocation != "" {
+				component.ExternalReferences = []cycloneDXExternalReference{{Type: "distribution", URL: pod.SourceLocation}}
+			}
+		}
+		bom.Components = append(bom.Components, component)
+
+		dependency := cycloneDXDependency{Ref: refs[pod.Name], DependsOn: []string{}}
+		for _, name := range pod.Dependencies {
+			if ref, ok := refs[name]; ok {
+				dependency.DependsOn = append(dependency.DependsOn, ref)
+			}
+		}
+		bom.Dependencies = append(bom.Dependencies, dependency)
+	}
+
+	return bom
+}
+
+// SPDXDocument is an SPDX 2.3 JSON document.
+type SPDXDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+var spdxIDInvalidCharsRegexp = regexp.MustCompile(`[^A-Za-z0-9.-]`)
+
+func spdxPackageID(name string) string {
+	return "SPDXRef-Pod-" + spdxIDInvalidCharsRegexp.ReplaceAllString(name, "-")
+}
+
+// NewSPDXDocument ...
+func NewSPDXDocument(pods []SBOMPod, documentName, serialNumber string, timestamp time.Time) SPDXDocument {
+	document := SPDXDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              documentName,
+		DocumentNamespace: "https://spdx.org/spdxdocs/cocoapods-" + serialNumber,
+		CreationInfo: spdxCreationInfo{
+			Created:  timestamp.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + sbomToolName},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+
+	for _, pod := range pods {
+		downloadLocation := "NOASSERTION"
+		if pod.Source == podSourceGit && pod.SourceLocation != "" {
+			downloadLocation = "git+" + pod.SourceLocation
+			if pod.SourceRevision != "" {
+				downloadLocation += "@" + pod.SourceRevision
+			}
+		}
+		licenseDeclared := "NOASSERTION"
+		if pod.License.SPDXID != "" {
+			licenseDeclared = pod.License.SPDXID
+		}
+
+		pkg := spdxPackage{
+			Name:             pod.Name,
+			SPDXID:           spdxPackageID(pod.Name),
+			VersionInfo:      pod.Version,
+			DownloadLocation: downloadLocation,
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  licenseDeclared,
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  podPURL(pod),
+			}},
+		}
+		if pod.Checksum != "" {
+			pkg.Checksums = []spdxChecksum{{Algorithm: "SHA1", ChecksumValue: pod.Checksum}}
+		}
+		document.Packages = append(document.Packages, pkg)
+
+		document.Relationships = append(document.Relationships, spdxRelationship{
+			SPDXElementID:      document.SPDXID,
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: pkg.SPDXID,
+		})
+		for _, dependency := range pod.Dependencies {
+			document.Relationships = append(document.Relationships, spdxRelationship{
+				SPDXElementID:      pkg.SPDXID,
+				RelationshipType:   "DEPENDS_ON",
+				RelatedSPDXElement: spdxPackageID(dependency),
+			})
+		}
+	}
+
+	return document
+}
+
+// newSerialNumber returns a random (version 4) UUID.
+func newSerialNumber() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func marshalSBOM(document interface{}) ([]byte, error) {
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// reportSBOM writes the software bill of materials of the installed pods.
+// Only the local files are used: Podfile.lock, the podspecs and the license files in the Pods directory.
+func (s Step) reportSBOM(config Config, pods []SBOMPod, result *Result) error {
+	if !isSBOMEnabled(config.SBOMFormat) {
+		return nil
+	}
+
+	s.logger.Printf("")
+	s.logger.Infof("Exporting SBOM")
+
+	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return fmt.Errorf("failed to generate SBOM serial number: %w", err)
+	}
+	now := time.Now()
+
+	content, err := marshalSBOM(NewCycloneDXBOM(pods, serialNumber, now))
+	if err != nil {
+		return fmt.Errorf("failed to encode SBOM: %w", err)
+	}
+	sbomPath := filepath.Join(config.OutputDir, "pods.cdx.json")
+	if err := os.WriteFile(sbomPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM: %w", err)
+	}
+	s.logger.Donef("CycloneDX SBOM of %d pods: %s", len(pods), sbomPath)
+	result.SBOMPath = sbomPath
+
+	var unknownLicenses []string
+	for _, pod := range pods {
+		if pod.License.Name == "" {
+			unknownLicenses = append(unknownLicenses, pod.Name)
+		}
+	}
+	if len(unknownLicenses) > 0 {
+		s.logger.Warnf("No license found for: %s", strings.Join(unknownLicenses, ", "))
+	}
+
+	if config.SBOMFormat != sbomFormatCycloneDXAndSPDX {
+		return nil
+	}
+
+	content, err = marshalSBOM(NewSPDXDocument(pods, filepath.Base(config.PodfileDir), serialNumber, now))
+	if err != nil {
+		return fmt.Errorf("failed to encode SPDX SBOM: %w", err)
+	}
+	spdxPath := filepath.Join(config.OutputDir, "pods.spdx.json")
+	if err := os.WriteFile(spdxPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write SPDX SBOM: %w", err)
+	}
+	s.logger.Donef("SPDX SBOM: %s", spdxPath)
+	result.SPDXSBOMPath = spdxPath
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenPodfileLock_WhenCollectingSBOMPods_ThenMergesSubspecs(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(podfileLockWithSourcesContent))
+	require.NoError(t, err)
+	licenses := map[string]PodLicense{"Alamofire": {Name: "MIT", SPDXID: "MIT"}}
+
+	// When
+	pods := sbomPods(lock, licenses)
+
+	// Then
+	require.Len(t, pods, 7)
+	require.Equal(t, SBOMPod{
+		Name:           "Alamofire",
+		Version:        "5.6.4",
+		Source:         podSourceTrunk,
+		SourceLocation: "https://cdn.cocoapods.org/",
+		Checksum:       "4e95d97098eacb88856099c4fc79b526a299e48c",
+		License:        PodLicense{Name: "MIT", SPDXID: "MIT"},
+	}, pods[0])
+	require.Equal(t, SBOMPod{
+		Name:           "Firebase",
+		Version:        "10.0.0",
+		Source:         podSourceTrunk,
+		SourceLocation: "https://cdn.cocoapods.org/",
+		Dependencies:   []string{"FirebaseAnalytics", "FirebaseCore"},
+	}, pods[1])
+	require.Equal(t, podSourceGit, pods[6].Source)
+	require.Equal(t, "https://github.com/SwiftyJSON/SwiftyJSON.git", pods[6].SourceLocation)
+	require.Equal(t, "5.0.1", pods[6].SourceRevision)
+}
+
+func Test_GivenGitPodWithLockedCommit_WhenCreatingDocuments_ThenPinsTheCommit(t *testing.T) {
+	// Given
+	lock, err := parsePodfileLock([]byte(podfileLockWithGitPodsContent))
+	require.NoError(t, err)
+	pods := sbomPods(lock, nil)
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// When
+	bom := NewCycloneDXBOM(pods, "5f0c1a2e-0000-4000-8000-000000000000", timestamp)
+	spdx := NewSPDXDocument(pods, "App", "5f0c1a2e-0000-4000-8000-000000000000", timestamp)
+
+	// Then
+	require.Equal(t, "DevKit", pods[0].Name)
+	require.Equal(t, "2f1e3c4d5b6a7980112233445566778899aabbcc", pods[0].SourceRevision)
+	require.Equal(t, []cycloneDXExternalReference{{Type: "vcs", URL: "https://github.com/company/DevKit.git#2f1e3c4d5b6a7980112233445566778899aabbcc"}}, bom.Components[0].ExternalReferences)
+	require.Contains(t, bom.Components[0].Properties, cycloneDXProperty{Name: "cocoapods:revision", Value: "2f1e3c4d5b6a7980112233445566778899aabbcc"})
+	require.Equal(t, "git+https://github.com/company/DevKit.git@2f1e3c4d5b6a7980112233445566778899aabbcc", spdx.Packages[0].DownloadLocation)
+
+	// NightlyKit tracks the default branch without a locked commit, only its repository is known.
+	require.Equal(t, "NightlyKit", pods[1].Name)
+	require.Equal(t, []cycloneDXExternalReference{{Type: "vcs", URL: "https://github.com/company/NightlyKit.git"}}, bom.Components[1].ExternalReferences)
+	require.Equal(t, "git+https://github.com/company/NightlyKit.git", spdx.Packages[1].DownloadLocation)
+}
+
+func Test_GivenSBOMPods_WhenCreatingDocuments_ThenDescribesPods(t *testing.T) {
+	// Given
+	pods := []SBOMPod{
+		{Name: "Alamofire", Version: "5.6.4", Source: podSourceTrunk, SourceLocation: "https://cdn.cocoapods.org/", Checksum: "4e95d97", License: PodLicense{Name: "MIT", SPDXID: "MIT"}},
+		{Name: "InternalKit", Version: "1.2.0", Source: podSourceGit, SourceLocation: "https://github.com/company/InternalKit.git", License: PodLicense{Name: "Commercial"}, Dependencies: []string{"Alamofire"}},
+	}
+	timestamp := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// When
+	bom := NewCycloneDXBOM(pods, "5f0c1a2e-0000-4000-8000-000000000000", timestamp)
+	spdx := NewSPDXDocument(pods, "App", "5f0c1a2e-0000-4000-8000-000000000000", timestamp)
+
+	// Then
+	require.Equal(t, "urn:uuid:5f0c1a2e-0000-4000-8000-000000000000", bom.SerialNumber)
+	require.Equal(t, "2024-01-01T12:00:00Z", bom.Metadata.Timestamp)
+	require.Equal(t, cycloneDXComponent{
+		Type:               "library",
+		BOMRef:             "pkg:cocoapods/Alamofire@5.6.4",
+		Name:               "Alamofire",
+		Version:            "5.6.4",
+		PURL:               "pkg:cocoapods/Alamofire@5.6.4",
+		Hashes:             []cycloneDXHash{{Alg: "SHA-1", Content: "4e95d97"}},
+		Licenses:           []cycloneDXLicenseChoice{{License: cycloneDXLicense{ID: "MIT"}}},
+		ExternalReferences: []cycloneDXExternalReference{{Type: "distribution", URL: "https://cdn.cocoapods.org/"}},
+		Properties:         []cycloneDXProperty{{Name: "cocoapods:source", Value: podSourceTrunk}},
+	}, bom.Components[0])
+	require.Equal(t, []cycloneDXLicenseChoice{{License: cycloneDXLicense{Name: "Commercial"}}}, bom.Components[1].Licenses)
+	require.Equal(t, []cycloneDXDependency{
+		{Ref: "pkg:cocoapods/Alamofire@5.6.4", DependsOn: []string{}},
+		{Ref: "pkg:cocoapods/InternalKit@1.2.0", DependsOn: []string{"pkg:cocoapods/Alamofire@5.6.4"}},
+	}, bom.Dependencies)
+
+	require.Equal(t, "SPDX-2.3", spdx.SPDXVersion)
+	require.Equal(t, "MIT", spdx.Packages[0].LicenseDeclared)
+	require.Equal(t, "NOASSERTION", spdx.Packages[1].LicenseDeclared)
+	require.Equal(t, "git+https://github.com/company/InternalKit.git", spdx.Packages[1].DownloadLocation)
+	require.Contains(t, spdx.Relationships, spdxRelationship{
+		SPDXElementID:      "SPDXRef-Pod-InternalKit",
+		RelationshipType:   "DEPENDS_ON",
+		RelatedSPDXElement: "SPDXRef-Pod-Alamofire",
+	})
+}
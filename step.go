@@ -0,0 +1,947 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/cache"
+	"github.com/bitrise-io/go-steputils/command/gems"
+	"github.com/bitrise-io/go-steputils/v2/ruby"
+	"github.com/bitrise-io/go-steputils/v2/stepconf"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/v2/analytics"
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-utils/v2/env"
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-utils/v2/pathutil"
+)
+
+// Input ...
+type Input struct {
+	Command                string `env:"command,opt[install,update]"`
+	SourceRootPath         string `env:"source_root_path,dir"`
+	PodfilePath            string `env:"podfile_path"`
+	GemfilePath            string `env:"gemfile_path"`
+	RubyInstallPolicy      string `env:"ruby_install_policy,opt[install-or-fail,install-or-warn,never-install]"`
+	VersionMismatchPolicy  string `env:"version_mismatch_policy,opt[prefer-gemfile,prefer-podfile-lock,fail]"`
+	IsolatedGemHome        bool   `env:"isolated_gem_home,opt[true,false]"`
+	PodInstallExtraArgs    string `env:"pod_install_extra_args"`
+	PodWorkdir             string `env:"pod_workdir,opt[podfile-dir,source-root]"`
+	PodEnv                 string `env:"pod_env"`
+	AllowedPodSources      string `env:"allowed_pod_sources"`
+	PluginVersions         string `env:"plugin_versions"`
+	CocoapodsKeys          string `env:"cocoapods_keys"`
+	ReactNativeNewArch     string `env:"react_native_new_arch,opt[default,enabled,disabled]"`
+	ReactNativeNoFlipper   bool   `env:"react_native_no_flipper,opt[true,false]"`
+	CPHomeDir              string `env:"cp_home_dir"`
+	PodInstallTimeout      int    `env:"pod_install_timeout,range[0..]"`
+	PodRepoUpdateTimeout   int    `env:"pod_repo_update_timeout,range[0..]"`
+	NoOutputTimeout        int    `env:"no_output_timeout,range[0..]"`
+	DependencyGraph        bool   `env:"dependency_graph,opt[true,false]"`
+	Why                    string `env:"why"`
+	SBOMFormat             string `env:"sbom_format,opt[none,cyclonedx,cyclonedx+spdx]"`
+	LicenseCheck           bool   `env:"license_check,opt[true,false]"`
+	LicenseAllowList       string `env:"license_allow_list"`
+	LicenseDenyList        string `env:"license_deny_list"`
+	LicenseViolationPolicy string `env:"license_violation_policy,opt[warn,fail]"`
+	AdvisoryDatabase       string `env:"advisory_database"`
+	FailOnSeverity         string `env:"fail_on_severity,opt[none,low,medium,high,critical]"`
+	OutputDir              string `env:"output_dir"`
+	VerifyPodChecksums     bool   `env:"verify_pod_checksums,opt[true,false]"`
+	StrictGitPods          bool   `env:"strict_git_pods,opt[true,false]"`
+	Verbose                bool   `env:"verbose,opt[true,false]"`
+	IsCacheDisabled        bool   `env:"is_cache_disabled,opt[true,false]"`
+}
+
+// Config ...
+type Config struct {
+	Command        string
+	SourceRootPath string
+	PodfilePath    string
+	PodfileDir     string
+	// PodWorkDir is the directory the Ruby, gem and pod commands are run in.
+	PodWorkDir            string
+	PodfileLockPath       string
+	GemfileLockPath       string
+	RubyInstallPolicy     string
+	VersionMismatchPolicy string
+	IsolatedGemHome       bool
+	PodExtraArgs          []string
+	PodEnvs               []string
+	PodSourcePolicy       PodSourcePolicy
+	PluginVersions        map[string]string
+	CocoapodsKeys         map[string]string
+	ReactNativeNewArch    string
+	ReactNativeNoFlipper  bool
+	CPHomeDir             string
+	PodInstallTimeouts    CommandTimeouts
+	PodRepoUpdateTimeouts CommandTimeouts
+	DependencyGraph       bool
+	WhyPods               []string
+	SBOMFormat            string
+	LicenseCheck          bool
+	LicensePolicy         LicensePolicy
+	FailOnLicenseIssues   bool
+	AdvisoryDatabase      string
+	FailOnSeverity        string
+	OutputDir             string
+	VerifyPodChecksums    bool
+	StrictGitPods         bool
+	Verbose               bool
+	IsCacheDisabled       bool
+}
+
+// Result ...
+type Result struct {
+	PodfileDir      string
+	PodfileLockPath string
+	// GemHome is the isolated GEM_HOME CocoaPods was installed into, empty if the global gems were used.
+	GemHome                 string
+	DependencyGraphJSONPath string
+	DependencyGraphDOTPath  string
+	SBOMPath                string
+	SPDXSBOMPath            string
+	AcknowledgementsPath    string
+	IsCacheDisabled         bool
+}
+
+// Step ...
+type Step struct {
+	envRepository  env.Repository
+	inputParser    stepconf.InputParser
+	cmdFactory     command.Factory
+	rubyCmdFactory ruby.CommandFactory
+	rubyEnv        ruby.Environment
+	rubyManager    RubyManager
+	pathChecker    pathutil.PathChecker
+	pathModifier   pathutil.PathModifier
+	tracker        analytics.Tracker
+	logger         log.Logger
+
+	phaseTimer *PhaseTimer
+	runReport  *RunReport
+}
+
+// NewStep ...
+func NewStep(
+	envRepository env.Repository,
+	inputParser stepconf.InputParser,
+	cmdFactory command.Factory,
+	rubyCmdFactory ruby.CommandFactory,
+	rubyEnv ruby.Environment,
+	rubyManager RubyManager,
+	pathChecker pathutil.PathChecker,
+	pathModifier pathutil.PathModifier,
+	tracker analytics.Tracker,
+	logger log.Logger,
+) Step {
+	return Step{
+		envRepository:  envRepository,
+		inputParser:    inputParser,
+		cmdFactory:     cmdFactory,
+		rubyCmdFactory: rubyCmdFactory,
+		rubyEnv:        rubyEnv,
+		rubyManager:    rubyManager,
+		pathChecker:    pathChecker,
+		pathModifier:   pathModifier,
+		tracker:        tracker,
+		logger:         logger,
+		phaseTimer:     NewPhaseTimer(),
+		runReport:      NewRunReport(),
+	}
+}
+
+// ProcessConfig parses the Step inputs and looks up the Podfile and the lockfiles.
+func (s Step) ProcessConfig() (Config, error) {
+	defer s.phaseTimer.Start(phaseDiscovery)()
+
+	var input Input
+	if err := s.inputParser.Parse(&input); err != nil {
+		return Config{}, err
+	}
+	stepconf.Print(input)
+
+	absSourceRootPath, err := s.pathModifier.AbsPath(input.SourceRootPath)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to expand (%s): %w", input.SourceRootPath, err)
+	}
+
+	podExtraArgs, err := parsePodExtraArgs(input.Command, input.PodInstallExtraArgs)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid pod_install_extra_args: %w", err)
+	}
+
+	podEnvs, err := parsePodEnvs(input.PodEnv)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid pod_env: %w", err)
+	}
+
+	pluginVersions, err := parsePluginVersions(input.PluginVersions)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid plugin_versions: %w", err)
+	}
+
+	cocoapodsKeys, err := parseCocoapodsKeys(input.CocoapodsKeys)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid cocoapods_keys: %w", err)
+	}
+
+	podfilePath, err := s.podfilePath(input.PodfilePath, absSourceRootPath)
+	if err != nil {
+		return Config{}, err
+	}
+	podfileDir := filepath.Dir(podfilePath)
+
+	podWorkDir := podfileDir
+	if input.PodWorkdir == podWorkdirSourceRoot {
+		if hasPodExtraArg(podExtraArgs, "--project-directory") {
+			return Config{}, fmt.Errorf("invalid pod_install_extra_args: --project-directory is set by the Step if pod_workdir is %s", podWorkdirSourceRoot)
+		}
+		podWorkDir = absSourceRootPath
+		if arg := projectDirectoryArg(podWorkDir, podfileDir); arg != "" {
+			podExtraArgs = append(podExtraArgs, arg)
+		}
+	}
+
+	podfileLockPath, err := s.podfileLockPath(podfileDir)
+	if err != nil {
+		return Config{}, err
+	}
+
+	gemfileLockPath, err := s.gemfileLockPath(input.GemfilePath, podfileDir, absSourceRootPath)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var advisoryDatabase string
+	if input.AdvisoryDatabase != "" {
+		if advisoryDatabase, err = s.pathModifier.AbsPath(input.AdvisoryDatabase); err != nil {
+			return Config{}, fmt.Errorf("failed to expand (%s): %w", input.AdvisoryDatabase, err)
+		}
+		if exists, err := s.pathChecker.IsPathExists(advisoryDatabase); err != nil {
+			return Config{}, fmt.Errorf("failed to check advisory database at: %s: %w", advisoryDatabase, err)
+		} else if !exists {
+			return Config{}, fmt.Errorf("advisory database does not exist at: %s", advisoryDatabase)
+		}
+	}
+
+	var outputDir string
+	if input.DependencyGraph || input.SBOMFormat != sbomFormatNone || input.LicenseCheck {
+		if input.OutputDir == "" {
+			return Config{}, fmt.Errorf("output_dir is required to export the dependency graph, the SBOM and the acknowledgements")
+		}
+		if outputDir, err = s.pathModifier.AbsPath(input.OutputDir); err != nil {
+			return Config{}, fmt.Errorf("failed to expand (%s): %w", input.OutputDir, err)
+		}
+	}
+
+	return Config{
+		Command:               input.Command,
+		SourceRootPath:        absSourceRootPath,
+		PodfilePath:           podfilePath,
+		PodfileDir:            podfileDir,
+		PodWorkDir:            podWorkDir,
+		PodfileLockPath:       podfileLockPath,
+		GemfileLockPath:       gemfileLockPath,
+		RubyInstallPolicy:     input.RubyInstallPolicy,
+		VersionMismatchPolicy: input.VersionMismatchPolicy,
+		IsolatedGemHome:       input.IsolatedGemHome,
+		PodExtraArgs:          podExtraArgs,
+		PodEnvs:               podEnvs,
+		PodSourcePolicy:       PodSourcePolicy{Allowed: parseList(input.AllowedPodSources)},
+		PluginVersions:        pluginVersions,
+		CocoapodsKeys:         cocoapodsKeys,
+		ReactNativeNewArch:    input.ReactNativeNewArch,
+		ReactNativeNoFlipper:  input.ReactNativeNoFlipper,
+		CPHomeDir:             input.CPHomeDir,
+		PodInstallTimeouts: CommandTimeouts{
+			Timeout:         time.Duration(input.PodInstallTimeout) * time.Minute,
+			NoOutputTimeout: time.Duration(input.NoOutputTimeout) * time.Minute,
+		},
+		PodRepoUpdateTimeouts: CommandTimeouts{
+			Timeout:         time.Duration(input.PodRepoUpdateTimeout) * time.Minute,
+			NoOutputTimeout: time.Duration(input.NoOutputTimeout) * time.Minute,
+		},
+		DependencyGraph: input.DependencyGraph,
+		WhyPods:         parseList(input.Why),
+		SBOMFormat:      input.SBOMFormat,
+		LicenseCheck:    input.LicenseCheck,
+		LicensePolicy: LicensePolicy{
+			Allow: parseList(input.LicenseAllowList),
+			Deny:  parseList(input.LicenseDenyList),
+		},
+		FailOnLicenseIssues: input.LicenseViolationPolicy == licenseViolationPolicyFail,
+		AdvisoryDatabase:    advisoryDatabase,
+		FailOnSeverity:      input.FailOnSeverity,
+		OutputDir:           outputDir,
+		VerifyPodChecksums:  input.VerifyPodChecksums,
+		StrictGitPods:       input.StrictGitPods,
+		Verbose:             input.Verbose,
+		IsCacheDisabled:     input.IsCacheDisabled,
+	}, nil
+}
+
+func (s Step) podfilePath(inputPodfilePath, absSourceRootPath string) (string, error) {
+	if inputPodfilePath != "" {
+		absPodfilePath, err := s.pathModifier.AbsPath(inputPodfilePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to expand (%s): %w", inputPodfilePath, err)
+		}
+
+		if exists, err := s.pathChecker.IsPathExists(absPodfilePath); err != nil {
+			return "", fmt.Errorf("failed to check Podfile at: %s: %w", absPodfilePath, err)
+		} else if !exists {
+			return "", fmt.Errorf("%s is not exist", inputPodfilePath)
+		}
+
+		s.logger.Printf("")
+		s.logger.Infof("Using Podfile: %s", absPodfilePath)
+
+		return absPodfilePath, nil
+	}
+
+	s.logger.Printf("")
+	s.logger.Infof("Searching for Podfile")
+
+	absPodfilePath, err := findMostRootPodfile(absSourceRootPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to find Podfile: %w", err)
+	}
+	if absPodfilePath == "" {
+		return "", fmt.Errorf("no Podfile found in: %s", absSourceRootPath)
+	}
+
+	s.logger.Donef("Found Podfile: %s", absPodfilePath)
+
+	return absPodfilePath, nil
+}
+
+func (s Step) podfileLockPath(podfileDir string) (string, error) {
+	s.logger.Printf("Searching for Podfile.lock")
+
+	podfileLockPath := filepath.Join(podfileDir, "Podfile.lock")
+	exists, err := s.pathChecker.IsPathExists(podfileLockPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to check Podfile.lock at: %s: %w", podfileLockPath, err)
+	}
+
+	if !exists {
+		s.logger.Warnf("No Podfile.lock found at: %s", podfileLockPath)
+		s.logger.Warnf("Make sure it's committed into your repository!")
+		return "", nil
+	}
+
+	s.logger.Printf("Found Podfile.lock: %s", podfileLockPath)
+
+	return podfileLockPath, nil
+}
+
+func (s Step) gemfileLockPath(inputGemfilePath, podfileDir, absSourceRootPath string) (string, error) {
+	if inputGemfilePath != "" {
+		absGemfilePath, err := s.pathModifier.AbsPath(inputGemfilePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to expand (%s): %w", inputGemfilePath, err)
+		}
+
+		if exists, err := s.pathChecker.IsPathExists(absGemfilePath); err != nil {
+			return "", fmt.Errorf("failed to check Gemfile at: %s: %w", absGemfilePath, err)
+		} else if !exists {
+			return "", fmt.Errorf("%s is not exist", inputGemfilePath)
+		}
+
+		s.logger.Printf("Using Gemfile: %s", absGemfilePath)
+
+		gemfileLockPath, err := gemfileLockPthForGemfile(absGemfilePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to determine gem lockfile path: %w", err)
+		}
+
+		if exists, err := s.pathChecker.IsPathExists(gemfileLockPath); err != nil {
+			return "", fmt.Errorf("failed to check gem lockfile at: %s: %w", gemfileLockPath, err)
+		} else if !exists {
+			s.logger.Warnf("No gem lockfile found at: %s", gemfileLockPath)
+			return "", nil
+		}
+
+		return gemfileLockPath, nil
+	}
+
+	s.logger.Printf("Searching for gem lockfile")
+
+	gemfileLockPath, err := findGemfileLock(podfileDir, absSourceRootPath)
+	if err != nil && err != gems.ErrGemLockNotFound {
+		return "", fmt.Errorf("failed to check gem lockfile at: %s: %w", podfileDir, err)
+	}
+
+	if gemfileLockPath != "" {
+		s.logger.Printf("Found gem lockfile: %s", gemfileLockPath)
+	}
+
+	return gemfileLockPath, nil
+}
+
+// Run selects the Ruby and CocoaPods versions and installs the Pods.
+func (s Step) Run(config Config) (Result, error) {
+	result := Result{
+		PodfileDir:      config.PodfileDir,
+		PodfileLockPath: config.PodfileLockPath,
+		IsCacheDisabled: config.IsCacheDisabled,
+	}
+
+	s.runReport.Command = config.Command
+	s.runReport.PodsManifestPresent = s.isPodsManifestPresent(config.PodfileDir)
+	s.runReport.PodCounts = s.podCountsBySource(config.PodfileLockPath)
+
+	s.checkSpecsRepoUsage(config.PodfilePath)
+
+	if err := s.checkPodSources(config); err != nil {
+		return result, withErrorCategory(errorCategorySourcePolicy, err)
+	}
+
+	keyEnvs, err := s.cocoapodsKeysEnvs(config)
+	if err != nil {
+		return result, withErrorCategory(errorCategoryInput, err)
+	}
+
+	projectEnvs, err := s.checkReactNativeProject(config)
+	if err != nil {
+		return result, withErrorCategory(errorCategoryNodeModules, err)
+	}
+
+	// pod install rewrites Podfile.lock, the installed pods and git checkouts are verified against the lockfile as it was before the install.
+	committedLock := s.readCommittedPodfileLock(config)
+
+	if config.VerifyPodChecksums && s.runReport.PodsManifestPresent {
+		if err := s.verifyExistingPodChecksums(config); err != nil {
+			return result, withErrorCategory(errorCategoryChecksum, err)
+		}
+	}
+
+	decision, err := s.determineCocoapodsVersion(config)
+	if err != nil {
+		return result, withErrorCategory(errorCategoryLockfile, err)
+	}
+	s.runReport.CocoapodsStrategy = decision.Strategy
+	s.runReport.CocoapodsVersion = decision.Version
+	s.runReport.BundlerVersion = bundlerVersionString(decision.BundlerVersion)
+
+	rubySelection, err := s.selectRubyVersion(config)
+	if err != nil {
+		return result, withErrorCategory(errorCategoryRubyInstall, err)
+	}
+	s.runReport.RubyVersion = rubySelection.EffectiveVersion
+
+	result.GemHome = s.isolatedGemHome(config, decision, rubySelection)
+	podEnvs := s.podEnvs(config, result.GemHome, projectEnvs)
+
+	installedGems, err := s.checkInstalledGems(decision, result.GemHome)
+	if err != nil {
+		return result, withErrorCategory(errorCategoryGemInstall, err)
+	}
+	decision = NewVersionResolver().ResolveInstalled(decision, installedGems)
+
+	skipped, err := s.installCocoapods(config, decision, result.GemHome, podEnvs)
+	if err != nil {
+		return result, withErrorCategory(errorCategoryGemInstall, err)
+	}
+	s.runReport.GemInstallSkipped = skipped
+
+	if err := s.installCocoapodsPlugins(config, decision, result.GemHome, podEnvs); err != nil {
+		return result, withErrorCategory(errorCategoryGemInstall, err)
+	}
+
+	if err := s.printCocoapodsVersion(decision.PodCmdPrefix, config.PodWorkDir, podEnvs); err != nil {
+		return result, withErrorCategory(errorCategoryGemInstall, err)
+	}
+
+	s.logger.Printf("")
+	s.logger.Infof("Installing Pods")
+
+	installer := NewCocoapodsInstaller(s.podCmdFactory(config.PodInstallTimeouts), s.podCmdFactory(config.PodRepoUpdateTimeouts), s.phaseTimer, s.logger)
+	// The key envs are only passed to the pod commands, they are never printed.
+	installEnvs := append(append([]string{}, podEnvs...), keyEnvs...)
+	retryCount, err := installer.InstallPods(decision.PodCmdPrefix, config.Command, config.PodExtraArgs, config.PodWorkDir, installEnvs, config.Verbose)
+	s.runReport.RetryCount = retryCount
+	if err != nil {
+		return result, withErrorCategory(errorCategoryPodInstall, fmt.Errorf("Failed to install Pods: %w", err))
+	}
+
+	if err := s.checkInstalledPodSources(config); err != nil {
+		return result, withErrorCategory(errorCategorySourcePolicy, err)
+	}
+
+	if config.VerifyPodChecksums {
+		if err := s.verifyInstalledPodChecksums(config, committedLock); err != nil {
+			return result, withErrorCategory(errorCategoryChecksum, err)
+		}
+	}
+
+	if err := s.checkGitPods(config, committedLock); err != nil {
+		return result, withErrorCategory(errorCategoryGitCheckout, err)
+	}
+
+	if err := s.writeReports(config, &result); err != nil {
+		return result, withErrorCategory(errorCategoryReport, err)
+	}
+
+	return result, nil
+}
+
+// readCommittedPodfileLock reads Podfile.lock before pod install, nil if the project has no (valid) Podfile.lock.
+func (s Step) readCommittedPodfileLock(config Config) *PodfileLock {
+	if config.PodfileLockPath == "" {
+		return nil
+	}
+	lock, err := readPodfileLock(config.PodfileLockPath)
+	if err != nil {
+		s.logger.Warnf("Failed to read Podfile.lock: %s", err)
+		return nil
+	}
+	return &lock
+}
+
+// writeReports writes the reports generated from the installed Podfile.lock.
+func (s Step) writeReports(config Config, result *Result) error {
+	if !config.DependencyGraph && len(config.WhyPods) == 0 && !isSBOMEnabled(config.SBOMFormat) && !config.LicenseCheck && config.AdvisoryDatabase == "" {
+		return nil
+	}
+
+	// pod install creates (or updates) Podfile.lock, even if it did not exist before the Step run.
+	lock, err := readPodfileLock(filepath.Join(config.PodfileDir, "Podfile.lock"))
+	if err != nil {
+		return fmt.Errorf("failed to read Podfile.lock: %w", err)
+	}
+
+	if err := s.reportDependencyGraph(config, lock, result); err != nil {
+		return err
+	}
+
+	if err := s.scanVulnerabilities(config, lock); err != nil {
+		return err
+	}
+
+	if !isSBOMEnabled(config.SBOMFormat) && !config.LicenseCheck {
+		return nil
+	}
+	pods := sbomPods(lock, readPodLicenses(config.PodfileDir, lock))
+
+	if err := s.reportSBOM(config, pods, result); err != nil {
+		return err
+	}
+
+	return s.checkLicenses(config, pods, result)
+}
+
+// Export exports the Step outputs and collects the Pods and the isolated GEM_HOME cache paths.
+func (s Step) Export(result Result) error {
+	outputs := []struct {
+		key   string
+		value string
+	}{
+		{key: "COCOAPODS_DEPENDENCY_GRAPH_JSON_PATH", value: result.DependencyGraphJSONPath},
+		{key: "COCOAPODS_DEPENDENCY_GRAPH_DOT_PATH", value: result.DependencyGraphDOTPath},
+		{key: "COCOAPODS_SBOM_PATH", value: result.SBOMPath},
+		{key: "COCOAPODS_SPDX_SBOM_PATH", value: result.SPDXSBOMPath},
+		{key: "COCOAPODS_ACKNOWLEDGEMENTS_PATH", value: result.AcknowledgementsPath},
+	}
+	for _, output := range outputs {
+		if output.value == "" {
+			continue
+		}
+		if err := s.exportOutput(output.key, output.value); err != nil {
+			return err
+		}
+	}
+
+	s.collectCache(result)
+
+	return nil
+}
+
+func (s Step) exportOutput(key, value string) error {
+	cmd := s.cmdFactory.Create("envman", []string{"add", "--key", key, "--value", value}, nil)
+	if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
+		return fmt.Errorf("failed to export %s: %w\noutput: %s", key, err, out)
+	}
+	s.logger.Donef("$%s = %s", key, value)
+	return nil
+}
+
+func (s Step) collectCache(result Result) {
+	defer s.phaseTimer.Start(phaseCacheCollection)()
+
+	if result.IsCacheDisabled || (result.PodfileLockPath == "" && result.GemHome == "") {
+		return
+	}
+
+	s.logger.Printf("")
+	s.logger.Infof("Collecting Pod cache paths...")
+
+	podsCache := cache.New()
+	if result.PodfileLockPath != "" {
+		podsCache.IncludePath(fmt.Sprintf("%s -> %s", filepath.Join(result.PodfileDir, "Pods"), result.PodfileLockPath))
+	}
+	if result.GemHome != "" {
+		// The GEM_HOME path contains the Ruby and CocoaPods versions, its content does not change once installed.
+		podsCache.IncludePath(result.GemHome)
+	}
+
+	if err := podsCache.Commit(); err != nil {
+		s.logger.Warnf("Cache collection skipped: failed to commit cache paths.")
+	}
+}
+
+// PrintTimings prints the duration of the Step phases and the slowest pods.
+// The timings are sent to analytics with the end of run event (TrackRun).
+func (s Step) PrintTimings() {
+	s.phaseTimer.PrintSummary(s.logger)
+}
+
+func (s Step) checkSpecsRepoUsage(podfilePath string) {
+	isUsingSpecsRepo, err := isPodfileUsingSpecsRepo(podfilePath)
+	if err != nil {
+		s.logger.Warnf("Failed to determine if Podfile is using Specs repo, error: %s", err)
+		return
+	}
+
+	if isUsingSpecsRepo {
+		addSpecsRepoAnnotation(s.cmdFactory)
+	}
+	s.runReport.IsUsingSpecsRepo = isUsingSpecsRepo
+}
+
+func (s Step) determineCocoapodsVersion(config Config) (CocoapodsVersionDecision, error) {
+	s.logger.Printf("")
+	s.logger.Infof("Determining required cocoapods version")
+
+	lockfiles, err := s.readCocoapodsLockfiles(config)
+	if err != nil {
+		return CocoapodsVersionDecision{}, err
+	}
+
+	if lockfiles.PodfileLockVersion != "" {
+		s.logger.Printf("CocoaPods version in Podfile.lock: %s", lockfiles.PodfileLockVersion)
+	}
+	if lockfiles.GemfileCocoapods.Found {
+		s.logger.Printf("CocoaPods version in gem lockfile: %s", lockfiles.GemfileCocoapods.Version)
+	}
+
+	decision, err := NewVersionResolver().Resolve(lockfiles, config.VersionMismatchPolicy)
+	if err != nil {
+		return CocoapodsVersionDecision{}, err
+	}
+
+	for _, warning := range decision.Warnings {
+		s.logger.Warnf(warning)
+	}
+	s.logger.Donef("Using %s CocoaPods: %s", decision.Strategy, decision.Reason)
+
+	if decision.Strategy == cocoapodsStrategyBundler {
+		// Make sure every bundle command uses the same Gemfile, even if it is not next to the Podfile.
+		gemfilePth := gemfilePthForGemfileLock(decision.GemfileLockPath)
+		if err := s.envRepository.Set("BUNDLE_GEMFILE", gemfilePth); err != nil {
+			return CocoapodsVersionDecision{}, fmt.Errorf("failed to set BUNDLE_GEMFILE: %w", err)
+		}
+		s.logger.Printf("BUNDLE_GEMFILE: %s", gemfilePth)
+	}
+
+	return decision, nil
+}
+
+func (s Step) readCocoapodsLockfiles(config Config) (CocoapodsLockfiles, error) {
+	lockfiles := CocoapodsLockfiles{
+		PodfileLockPath: config.PodfileLockPath,
+		GemfileLockPath: config.GemfileLockPath,
+	}
+
+	if config.PodfileLockPath != "" {
+		version, err := cocoapodsVersionFromPodfileLock(config.PodfileLockPath)
+		if err != nil {
+			return CocoapodsLockfiles{}, fmt.Errorf("failed to determine CocoaPods version: %w", err)
+		}
+		lockfiles.PodfileLockVersion = version
+	}
+
+	if config.GemfileLockPath == "" {
+		return lockfiles, nil
+	}
+
+	content, err := fileutil.ReadStringFromFile(config.GemfileLockPath)
+	if err != nil {
+		return CocoapodsLockfiles{}, fmt.Errorf("failed to read file (%s) contents: %w", config.GemfileLockPath, err)
+	}
+
+	lockfiles.GemfileCocoapods, err = gems.ParseVersionFromBundle("cocoapods", content)
+	if err != nil {
+		return CocoapodsLockfiles{}, fmt.Errorf("failed to check if gem lockfile contains cocoapods: %w", err)
+	}
+
+	lockfiles.GemfileBundler, err = gems.ParseBundlerVersion(content)
+	if err != nil {
+		return CocoapodsLockfiles{}, fmt.Errorf("failed to parse bundler version form cocoapods: %w", err)
+	}
+
+	return lockfiles, nil
+}
+
+func (s Step) selectRubyVersion(config Config) (RubyVersionSelection, error) {
+	s.logger.Printf("")
+	s.logger.Infof("Checking selected Ruby version (%s)", s.rubyManager.Name())
+
+	stopTimer := s.phaseTimer.Start(phaseRubySelection)
+	rubySelectStart := time.Now()
+	rubySelection, err := NewRubyVersionSelector(s.rubyManager, s.envRepository, s.logger).SelectRubyVersion(config.PodWorkDir, config.RubyInstallPolicy)
+	stopTimer()
+	if err != nil {
+		return RubyVersionSelection{}, err
+	}
+	s.runReport.RubyRequest = rubySelection.Request
+	s.runReport.RubyVersionChangeDuration = time.Since(rubySelectStart)
+
+	return rubySelection, nil
+}
+
+// podCmdFactory returns the factory for the pod commands, which kills the commands on timeout if any timeout is configured.
+// pod commands never require sudo, so the Ruby command factory can be replaced for them.
+func (s Step) podCmdFactory(timeouts CommandTimeouts) command.Factory {
+	if !timeouts.IsSet() {
+		return s.rubyCmdFactory
+	}
+	return NewWatchdogCommandFactory(s.envRepository, timeouts)
+}
+
+// podEnvs returns the environment of the pod and bundle commands.
+func (s Step) podEnvs(config Config, gemHome string, projectEnvs []string) []string {
+	var extraEnvs []string
+	if gemHome != "" {
+		extraEnvs = gemHomeEnvs(gemHome, s.envRepository.Get("PATH"))
+	}
+	// The pod_env input comes last, so that it overrides the envs set by the Step.
+	extraEnvs = append(extraEnvs, projectEnvs...)
+	extraEnvs = append(extraEnvs, config.PodEnvs...)
+
+	envs := cocoapodsEnvs(s.envRepository, config.CPHomeDir, extraEnvs)
+
+	s.logger.Printf("")
+	s.logger.Printf("CocoaPods environment:")
+	for _, e := range redactedEnvs(envs) {
+		s.logger.Printf("- %s", e)
+	}
+
+	return envs
+}
+
+// isolatedGemHome returns the GEM_HOME to install the Podfile.lock's CocoaPods version into, if the isolated_gem_home input is enabled.
+func (s Step) isolatedGemHome(config Config, decision CocoapodsVersionDecision, rubySelection RubyVersionSelection) string {
+	if !config.IsolatedGemHome {
+		return ""
+	}
+
+	if decision.Strategy != cocoapodsStrategyPodfileLock {
+		s.logger.Printf("Isolated GEM_HOME is only used for the CocoaPods version from Podfile.lock (CocoaPods is provided by: %s)", decision.Strategy)
+		return ""
+	}
+
+	if rubySelection.EffectiveVersion == "" {
+		s.logger.Warnf("Unknown Ruby version, installing CocoaPods into the global gems")
+		return ""
+	}
+
+	gemHome := isolatedGemHomeDir(s.envRepository.Get("HOME"), rubySelection.EffectiveVersion, decision.Version)
+	s.logger.Printf("Isolated GEM_HOME: %s", gemHome)
+
+	return gemHome
+}
+
+// checkInstalledGems checks the gem providing the decided CocoaPods version in the selected Ruby version.
+// The CocoaPods installed into the isolated GEM_HOME are checked by installCocoapodsIntoGemHome.
+func (s Step) checkInstalledGems(decision CocoapodsVersionDecision, gemHome string) (InstalledGems, error) {
+	var installedGems InstalledGems
+
+	switch decision.Strategy {
+	case cocoapodsStrategyBundler:
+		installed, err := s.rubyEnv.IsGemInstalled("bundler", decision.BundlerVersion.Version)
+		if err != nil {
+			s.logger.Warnf("Failed to check if bundler %s installed, error: %s", decision.BundlerVersion.Version, err)
+		}
+		installedGems.Bundler = installed
+	case cocoapodsStrategyPodfileLock:
+		if gemHome != "" {
+			break
+		}
+		installed, err := s.rubyEnv.IsGemInstalled("cocoapods", decision.Version)
+		if err != nil {
+			return InstalledGems{}, fmt.Errorf("failed to check if cocoapods %s installed: %w", decision.Version, err)
+		}
+		installedGems.Cocoapods = installed
+	}
+
+	return installedGems, nil
+}
+
+// installCocoapods installs the required CocoaPods version, returns true if it was already installed.
+func (s Step) installCocoapods(config Config, decision CocoapodsVersionDecision, gemHome string, podEnvs []string) (bool, error) {
+	s.logger.Printf("")
+	s.logger.Infof("Installing cocoapods")
+
+	switch decision.Strategy {
+	case cocoapodsStrategyBundler:
+		return s.installBundle(decision, podEnvs)
+	case cocoapodsStrategyPodfileLock:
+		defer s.phaseTimer.Start(phaseCocoapodsGemInstall)()
+
+		s.logger.Printf("Checking cocoapods %s gem", decision.Version)
+
+		if gemHome != "" {
+			return s.installCocoapodsIntoGemHome(config, decision, gemHome, podEnvs)
+		}
+
+		if decision.Installed {
+			s.logger.Printf("Installed")
+			return true, nil
+		}
+
+		s.logger.Printf("Installing")
+
+		cmds := s.rubyCmdFactory.CreateGemInstall("cocoapods", decision.Version, false, false, &command.Opts{Env: podEnvs, Dir: config.PodWorkDir})
+		for _, cmd := range cmds {
+			s.logger.Donef("$ %s", cmd.PrintableCommandArgs())
+
+			if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
+				return false, fmt.Errorf("command failed: %w\noutput: %s", err, out)
+			}
+		}
+	default:
+		s.logger.Printf("Using system installed cocoapods")
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (s Step) installCocoapodsIntoGemHome(config Config, decision CocoapodsVersionDecision, gemHome string, podEnvs []string) (bool, error) {
+	installed, err := s.pathChecker.IsPathExists(gemSpecPth(gemHome, "cocoapods", decision.Version))
+	if err != nil {
+		return false, fmt.Errorf("failed to check if cocoapods %s installed in %s: %w", decision.Version, gemHome, err)
+	}
+
+	if installed {
+		s.logger.Printf("Installed in %s", gemHome)
+		return true, nil
+	}
+
+	s.logger.Printf("Installing into %s", gemHome)
+
+	// The plain command factory is used, as installing into the Step owned GEM_HOME does not require sudo (and sudo would drop GEM_HOME).
+	cmd := s.cmdFactory.Create("gem", []string{"install", "cocoapods", "--no-document", "-v", decision.Version}, &command.Opts{
+		Env: podEnvs,
+		Dir: config.PodWorkDir,
+	})
+	s.logger.Donef("$ %s", cmd.PrintableCommandArgs())
+
+	if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
+		return false, fmt.Errorf("command failed: %w\noutput: %s", err, out)
+	}
+
+	return false, nil
+}
+
+// installBundle installs the gem lockfile gems, returns true if bundler and all the gems were already installed.
+func (s Step) installBundle(decision CocoapodsVersionDecision, envs []string) (bool, error) {
+	defer s.phaseTimer.Start(phaseBundlerInstall)()
+
+	bundlerVersion := decision.BundlerVersion
+	gemfileDir := filepath.Dir(decision.GemfileLockPath)
+
+	s.logger.Printf("")
+	s.logger.Infof("Checking bundler")
+
+	bundlerInstalled := decision.Installed
+	if !bundlerInstalled {
+		s.logger.Printf("")
+		s.logger.Infof("Installing bundler")
+
+		// install bundler with `gem install bundler [-v version]`
+		// in some configurations, the command "bunder _1.2.3_" can return 'Command not found', installing bundler solves this
+		cmds := s.rubyCmdFactory.CreateGemInstall("bundler", bundlerVersion.Version, false, true, &command.Opts{
+			Stdout: os.Stdout,
+			Stderr: os.Stderr,
+			Env:    envs,
+			Dir:    gemfileDir,
+		})
+		for _, cmd := range cmds {
+			s.logger.Donef("$ %s", cmd.PrintableCommandArgs())
+			s.logger.Printf("")
+
+			if err := cmd.Run(); err != nil {
+				return false, fmt.Errorf("command failed: %w", err)
+			}
+		}
+	} else {
+		s.logger.Donef("Bundler %s is installed", bundlerVersion.Version)
+	}
+
+	// check if the gem lockfile gems are already installed with `bundle [_version_] check`
+	s.logger.Printf("")
+	s.logger.Infof("Checking installed gems")
+
+	checkCmdSlice := bundleCheckCmdSlice(bundlerVersion)
+	checkCmd := s.rubyCmdFactory.Create(checkCmdSlice[0], checkCmdSlice[1:], &command.Opts{Env: envs, Dir: gemfileDir})
+	s.logger.Donef("$ %s", checkCmd.PrintableCommandArgs())
+
+	out, err := checkCmd.RunAndReturnTrimmedCombinedOutput()
+	if err == nil {
+		s.logger.Donef("All gems are installed, skipping bundle install")
+		return bundlerInstalled, nil
+	}
+	s.logger.Printf("%s", out)
+
+	// install gem lockfile gems with `bundle [_version_] install ...`
+	s.logger.Printf("")
+	s.logger.Infof("Installing cocoapods with bundler")
+
+	cmd := s.rubyCmdFactory.CreateBundleInstall(bundlerVersionString(bundlerVersion), &command.Opts{
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Env:    envs,
+		Dir:    gemfileDir,
+	})
+	s.logger.Donef("$ %s", cmd.PrintableCommandArgs())
+	s.logger.Printf("")
+
+	if err := cmd.Run(); err != nil {
+		return false, fmt.Errorf("command failed: %w", err)
+	}
+
+	return false, nil
+}
+
+func (s Step) printCocoapodsVersion(podCmdSlice []string, workDir string, podEnvs []string) error {
+	s.logger.Printf("")
+	s.logger.Infof("cocoapods version:")
+
+	// pod can be in the PATH as an rbenv shim and pod --version will return "rbenv: pod: command not found"
+	cmdSlice := append(append([]string{}, podCmdSlice...), "--version")
+	cmd := s.rubyCmdFactory.Create(cmdSlice[0], cmdSlice[1:], &command.Opts{
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Env:    podEnvs,
+		Dir:    workDir,
+	})
+
+	s.logger.Donef("$ %s", cmd.PrintableCommandArgs())
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command failed: %w", err)
+	}
+
+	return nil
+}
+
+func bundlerVersionString(bundlerVersion gems.Version) string {
+	if !bundlerVersion.Found {
+		return ""
+	}
+	return bundlerVersion.Version
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bitrise-steplib/steps-cocoapods-install/mocks"
+
+	"github.com/bitrise-io/go-steputils/v2/ruby"
+	"github.com/bitrise-io/go-steputils/v2/stepconf"
+	"github.com/bitrise-io/go-utils/v2/analytics"
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-utils/v2/env"
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-utils/v2/pathutil"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTracker struct {
+	events map[string]analytics.Properties
+}
+
+func (t *fakeTracker) Enqueue(eventName string, properties ...analytics.Properties) {
+	if t.events == nil {
+		t.events = map[string]analytics.Properties{}
+	}
+	merged := analytics.Properties{}
+	for _, p := range properties {
+		for k, v := range p {
+			merged[k] = v
+		}
+	}
+	t.events[eventName] = merged
+}
+
+func (t *fakeTracker) Wait() {}
+
+type fakeRubyEnvironment struct {
+	installedGems map[string]bool
+}
+
+func (e fakeRubyEnvironment) RubyInstallType() ruby.InstallType {
+	return ruby.RbenvRuby
+}
+
+func (e fakeRubyEnvironment) IsGemInstalled(gem, version string) (bool, error) {
+	return e.installedGems[gem+" "+version], nil
+}
+
+func (e fakeRubyEnvironment) IsSpecifiedRbenvRubyInstalled(string) (bool, string, error) {
+	return false, "", nil
+}
+
+func (e fakeRubyEnvironment) IsSpecifiedASDFRubyInstalled(string) (bool, string, error) {
+	return false, "", nil
+}
+
+func createTestProject(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		pth := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+		require.NoError(t, os.WriteFile(pth, []byte(content), 0644))
+	}
+	return dir
+}
+
+func createTestStep(cmdFactory *mocks.CommandFactory, rubyEnv ruby.Environment, tracker analytics.Tracker) Step {
+	envRepository := env.NewRepository()
+	rubyManager := &fakeRubyManager{name: systemRubyManagerName, effectiveVersion: "3.2.0"}
+	return NewStep(
+		envRepository,
+		stepconf.NewInputParser(envRepository),
+		cmdFactory,
+		cmdFactory,
+		rubyEnv,
+		rubyManager,
+		pathutil.NewPathChecker(),
+		pathutil.NewPathModifier(),
+		tracker,
+		log.NewLogger(),
+	)
+}
+
+// setTestInputs sets the Step inputs to their default values, overridden by the given inputs.
+func setTestInputs(t *testing.T, projectDir string, inputs map[string]string) {
+	defaults := map[string]string{
+		"command":                  "install",
+		"source_root_path":         projectDir,
+		"podfile_path":             "",
+		"gemfile_path":             "",
+		"pod_install_extra_args":   "",
+		"ruby_install_policy":      rubyInstallPolicyInstallOrFail,
+		"version_mismatch_policy":  versionMismatchPolicyPreferGemfile,
+		"pod_workdir":              podWorkdirPodfileDir,
+		"isolated_gem_home":        "false",
+		"dependency_graph":         "false",
+		"sbom_format":              sbomFormatNone,
+		"license_check":            "false",
+		"license_violation_policy": licenseViolationPolicyWarn,
+		"fail_on_severity":         severityNone,
+		"verify_pod_checksums":     "false",
+		"strict_git_pods":          "false",
+		"react_native_new_arch":    reactNativeNewArchDefault,
+		"react_native_no_flipper":  "false",
+		"verbose":                  "false",
+		"is_cache_disabled":        "false",
+	}
+	for key, value := range inputs {
+		defaults[key] = value
+	}
+	for key, value := range defaults {
+		t.Setenv(key, value)
+	}
+}
+
+// testRunConfig returns the Run config of a project with the Podfile in the project directory.
+func testRunConfig(projectDir string) Config {
+	return Config{
+		Command:               "install",
+		SourceRootPath:        projectDir,
+		PodfilePath:           filepath.Join(projectDir, "Podfile"),
+		PodfileDir:            projectDir,
+		PodWorkDir:            projectDir,
+		RubyInstallPolicy:     rubyInstallPolicyInstallOrFail,
+		VersionMismatchPolicy: versionMismatchPolicyPreferGemfile,
+	}
+}
+
+func expectCommand(cmdFactory *mocks.CommandFactory, name string, args []string) *mocks.Command {
+	cmd := new(mocks.Command)
+	cmd.On("PrintableCommandArgs").Return(name)
+	cmd.On("Run").Return(nil).Maybe()
+	cmd.On("RunAndReturnTrimmedCombinedOutput").Return("", nil).Maybe()
+	cmdFactory.On("Create", name, args, mock.Anything).Return(cmd).Once()
+	return cmd
+}
+
+func containsAll(list, items []string) bool {
+	for _, item := range items {
+		found := false
+		for _, e := range list {
+			if e == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func Test_GivenProjectWithPodfileInSubdirectory_WhenProcessingConfig_ThenFindsPodfileAndLockfiles(t *testing.T) {
+	// Given
+	projectDir := createTestProject(t, map[string]string{
+		"ios/Podfile":      "platform :ios, '13.0'\n",
+		"ios/Podfile.lock": podfileLockContent,
+		"Gemfile.lock":     gemfileLockContent,
+	})
+
+	setTestInputs(t, projectDir, nil)
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	config, err := step.ProcessConfig()
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, Config{
+		Command:               "install",
+		SourceRootPath:        projectDir,
+		PodfilePath:           filepath.Join(projectDir, "ios", "Podfile"),
+		PodfileDir:            filepath.Join(projectDir, "ios"),
+		PodWorkDir:            filepath.Join(projectDir, "ios"),
+		PodfileLockPath:       filepath.Join(projectDir, "ios", "Podfile.lock"),
+		GemfileLockPath:       filepath.Join(projectDir, "Gemfile.lock"),
+		RubyInstallPolicy:     rubyInstallPolicyInstallOrFail,
+		VersionMismatchPolicy: versionMismatchPolicyPreferGemfile,
+		SBOMFormat:            sbomFormatNone,
+		FailOnSeverity:        severityNone,
+		ReactNativeNewArch:    reactNativeNewArchDefault,
+	}, config)
+}
+
+func Test_GivenSourceRootWorkdir_WhenProcessingConfig_ThenPassesProjectDirectory(t *testing.T) {
+	// Given
+	projectDir := createTestProject(t, map[string]string{
+		"ios/Podfile":      "platform :ios, '13.0'\n",
+		"ios/Podfile.lock": podfileLockContent,
+	})
+
+	setTestInputs(t, projectDir, map[string]string{
+		"pod_install_extra_args": "--clean-install",
+		"pod_workdir":            podWorkdirSourceRoot,
+	})
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	config, err := step.ProcessConfig()
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(projectDir, "ios"), config.PodfileDir)
+	require.Equal(t, projectDir, config.PodWorkDir)
+	require.Equal(t, []string{"--clean-install", "--project-directory=ios"}, config.PodExtraArgs)
+
+	// When
+	t.Setenv("pod_install_extra_args", "--project-directory=ios")
+	_, err = step.ProcessConfig()
+
+	// Then
+	require.EqualError(t, err, "invalid pod_install_extra_args: --project-directory is set by the Step if pod_workdir is source-root")
+}
+
+func Test_GivenNonExistingPodfilePath_WhenProcessingConfig_ThenFails(t *testing.T) {
+	// Given
+	projectDir := createTestProject(t, nil)
+
+	setTestInputs(t, projectDir, map[string]string{
+		"podfile_path": filepath.Join(projectDir, "Podfile"),
+	})
+
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	_, err := step.ProcessConfig()
+
+	// Then
+	require.EqualError(t, err, filepath.Join(projectDir, "Podfile")+" is not exist")
+}
+
+func Test_GivenPodfileLockWithoutGemfile_WhenRunning_ThenInstallsPodfileLockCocoapodsVersion(t *testing.T) {
+	// Given
+	projectDir := createTestProject(t, map[string]string{
+		"Podfile":      "platform :ios, '13.0'\n",
+		"Podfile.lock": podfileLockContent,
+	})
+
+	cmdFactory := new(mocks.CommandFactory)
+	gemInstallCmd := new(mocks.Command)
+	gemInstallCmd.On("PrintableCommandArgs").Return("gem install cocoapods")
+	gemInstallCmd.On("RunAndReturnTrimmedCombinedOutput").Return("", nil)
+	cmdFactory.On("CreateGemInstall", "cocoapods", "1.11.3", false, false, mock.Anything).Return([]command.Command{gemInstallCmd})
+	versionCmd := expectCommand(cmdFactory, "pod", []string{"_1.11.3_", "--version"})
+	installCmd := expectCommand(cmdFactory, "pod", []string{"_1.11.3_", "install", "--no-repo-update"})
+
+	tracker := &fakeTracker{}
+	step := createTestStep(cmdFactory, fakeRubyEnvironment{}, tracker)
+
+	// When
+	config := testRunConfig(projectDir)
+	config.PodfileLockPath = filepath.Join(projectDir, "Podfile.lock")
+	result, err := step.Run(config)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, Result{PodfileDir: projectDir, PodfileLockPath: filepath.Join(projectDir, "Podfile.lock")}, result)
+	cmdFactory.AssertExpectations(t)
+	gemInstallCmd.AssertExpectations(t)
+	versionCmd.AssertExpectations(t)
+	installCmd.AssertExpectations(t)
+	require.Empty(t, tracker.events)
+
+	step.TrackRun(err)
+	require.Len(t, tracker.events, 1)
+	event := tracker.events["step_cocoapods_install_finished"]
+	require.Equal(t, false, event["is_using_specs_repo"])
+	require.Equal(t, "", event["requested_ruby_version"])
+	require.Equal(t, "3.2.0", event["ruby_version"])
+	require.Contains(t, event, "version_change_duration_s")
+}
+
+func Test_GivenIsolatedGemHome_WhenRunning_ThenInstallsCocoapodsIntoGemHome(t *testing.T) {
+	// Given
+	projectDir := createTestProject(t, map[string]string{
+		"Podfile":      "platform :ios, '13.0'\n",
+		"Podfile.lock": podfileLockContent,
+	})
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("PATH", "/usr/bin")
+
+	gemHome := filepath.Join(homeDir, ".bitrise", "cocoapods-install", "gems", "ruby-3.2.0", "cocoapods-1.11.3")
+	wantEnvs := []string{"GEM_HOME=" + gemHome, "PATH=" + filepath.Join(gemHome, "bin") + ":/usr/bin"}
+	withGemHomeEnvs := mock.MatchedBy(func(opts *command.Opts) bool {
+		return opts != nil && containsAll(opts.Env, wantEnvs)
+	})
+
+	cmdFactory := new(mocks.CommandFactory)
+	gemInstallCmd := new(mocks.Command)
+	gemInstallCmd.On("PrintableCommandArgs").Return("gem install cocoapods")
+	gemInstallCmd.On("RunAndReturnTrimmedCombinedOutput").Return("", nil)
+	cmdFactory.On("Create", "gem", []string{"install", "cocoapods", "--no-document", "-v", "1.11.3"}, withGemHomeEnvs).Return(gemInstallCmd).Once()
+	versionCmd := new(mocks.Command)
+	versionCmd.On("PrintableCommandArgs").Return("pod _1.11.3_ --version")
+	versionCmd.On("Run").Return(nil)
+	cmdFactory.On("Create", "pod", []string{"_1.11.3_", "--version"}, withGemHomeEnvs).Return(versionCmd).Once()
+	installCmd := new(mocks.Command)
+	installCmd.On("PrintableCommandArgs").Return("pod _1.11.3_ install")
+	installCmd.On("Run").Return(nil)
+	cmdFactory.On("Create", "pod", []string{"_1.11.3_", "install", "--no-repo-update"}, withGemHomeEnvs).Return(installCmd).Once()
+
+	step := createTestStep(cmdFactory, fakeRubyEnvironment{installedGems: map[string]bool{"cocoapods 1.11.3": true}}, &fakeTracker{})
+
+	// When
+	config := testRunConfig(projectDir)
+	config.PodfileLockPath = filepath.Join(projectDir, "Podfile.lock")
+	config.IsolatedGemHome = true
+	result, err := step.Run(config)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, gemHome, result.GemHome)
+	cmdFactory.AssertExpectations(t)
+	gemInstallCmd.AssertExpectations(t)
+	versionCmd.AssertExpectations(t)
+	installCmd.AssertExpectations(t)
+}
+
+// notSelectedRubyEnvironment fails the gem checks until the Ruby manager installs the requested Ruby version,
+// like gem list of an rbenv shim does if the .ruby-version is not installed.
+type notSelectedRubyEnvironment struct {
+	fakeRubyEnvironment
+	rubyManager *fakeRubyManager
+}
+
+func (e notSelectedRubyEnvironment) IsGemInstalled(gem, version string) (bool, error) {
+	if len(e.rubyManager.installed) == 0 {
+		return false, errors.New("rbenv: version `3.3.0' is not installed")
+	}
+	return e.fakeRubyEnvironment.IsGemInstalled(gem, version)
+}
+
+func Test_GivenRequestedRubyNotInstalled_WhenRunning_ThenChecksGemsAfterRubySelection(t *testing.T) {
+	// Given
+	projectDir := createTestProject(t, map[string]string{
+		"Podfile":       "platform :ios, '13.0'\n",
+		"Podfile.lock":  podfileLockContent,
+		".ruby-version": "3.3.0\n",
+	})
+	t.Setenv("CI", "true")
+
+	cmdFactory := new(mocks.CommandFactory)
+	versionCmd := expectCommand(cmdFactory, "pod", []string{"_1.11.3_", "--version"})
+	installCmd := expectCommand(cmdFactory, "pod", []string{"_1.11.3_", "install", "--no-repo-update"})
+
+	rubyManager := &fakeRubyManager{
+		name:             rbenvRubyManagerName,
+		request:          RubyVersionRequest{Version: "3.3.0", Source: filepath.Join(projectDir, ".ruby-version")},
+		effectiveVersion: "3.3.0",
+	}
+	rubyEnv := notSelectedRubyEnvironment{
+		fakeRubyEnvironment: fakeRubyEnvironment{installedGems: map[string]bool{"cocoapods 1.11.3": true}},
+		rubyManager:         rubyManager,
+	}
+	step := createTestStep(cmdFactory, rubyEnv, &fakeTracker{})
+	step.rubyManager = rubyManager
+
+	// When
+	config := testRunConfig(projectDir)
+	config.PodfileLockPath = filepath.Join(projectDir, "Podfile.lock")
+	_, err := step.Run(config)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, []string{"3.3.0"}, rubyManager.installed)
+	cmdFactory.AssertExpectations(t)
+	versionCmd.AssertExpectations(t)
+	installCmd.AssertExpectations(t)
+}
+
+func Test_GivenGemfileLockWithInstalledGems_WhenRunning_ThenSkipsBundleInstall(t *testing.T) {
+	// Given
+	projectDir := createTestProject(t, map[string]string{
+		"Podfile":            "platform :ios, '13.0'\n",
+		"Podfile.lock":       podfileLockContent,
+		"Gemfile.lock":       gemfileLockContent,
+		"Pods/Manifest.lock": podfileLockContent,
+	})
+	t.Setenv("BUNDLE_GEMFILE", "")
+
+	cmdFactory := new(mocks.CommandFactory)
+	checkCmd := expectCommand(cmdFactory, "bundle", []string{"_2.4.10_", "check"})
+	versionCmd := expectCommand(cmdFactory, "bundle", []string{"_2.4.10_", "exec", "pod", "--version"})
+	installCmd := expectCommand(cmdFactory, "bundle", []string{"_2.4.10_", "exec", "pod", "install", "--no-repo-update"})
+
+	rubyEnv := fakeRubyEnvironment{installedGems: map[string]bool{"bundler 2.4.10": true}}
+	tracker := &fakeTracker{}
+	step := createTestStep(cmdFactory, rubyEnv, tracker)
+
+	// When
+	config := testRunConfig(projectDir)
+	config.PodfileLockPath = filepath.Join(projectDir, "Podfile.lock")
+	config.GemfileLockPath = filepath.Join(projectDir, "Gemfile.lock")
+	_, err := step.Run(config)
+
+	// Then
+	require.NoError(t, err)
+	cmdFactory.AssertExpectations(t)
+	checkCmd.AssertExpectations(t)
+	versionCmd.AssertExpectations(t)
+	installCmd.AssertExpectations(t)
+	require.Equal(t, filepath.Join(projectDir, "Gemfile"), os.Getenv("BUNDLE_GEMFILE"))
+
+	step.TrackRun(err)
+	event := tracker.events["step_cocoapods_install_finished"]
+	require.Equal(t, "install", event["command"])
+	require.Equal(t, cocoapodsStrategyBundler, event["cocoapods_strategy"])
+	require.Equal(t, "1.11.3", event["cocoapods_version"])
+	require.Equal(t, "2.4.10", event["bundler_version"])
+	require.Equal(t, systemRubyManagerName, event["ruby_manager"])
+	require.Equal(t, "3.2.0", event["ruby_version"])
+	require.Equal(t, true, event["pods_manifest_present"])
+	require.Equal(t, true, event["gem_install_skipped"])
+	require.Equal(t, 0, event["retry_count"])
+	require.Equal(t, true, event["is_success"])
+	require.Equal(t, "", event["error_category"])
+	require.Equal(t, 1, event["trunk_pods"])
+	require.Equal(t, 0, event["git_pods"])
+	require.Contains(t, event, phaseRubySelection+"_ms")
+	require.Contains(t, event, phaseBundlerInstall+"_ms")
+	require.Contains(t, event, "slowest_pods")
+	require.NotContains(t, tracker.events, "step_cocoapods_install_timings")
+}
+
+func Test_GivenGemfileLockWithMissingGems_WhenRunning_ThenInstallsBundlerAndBundle(t *testing.T) {
+	// Given
+	projectDir := createTestProject(t, map[string]string{
+		"Podfile":      "platform :ios, '13.0'\n",
+		"Gemfile.lock": gemfileLockContent,
+	})
+	t.Setenv("BUNDLE_GEMFILE", "")
+
+	cmdFactory := new(mocks.CommandFactory)
+	bundlerInstallCmd := new(mocks.Command)
+	bundlerInstallCmd.On("PrintableCommandArgs").Return("gem install bundler")
+	bundlerInstallCmd.On("Run").Return(nil)
+	cmdFactory.On("CreateGemInstall", "bundler", "2.4.10", false, true, mock.Anything).Return([]command.Command{bundlerInstallCmd})
+
+	checkCmd := new(mocks.Command)
+	checkCmd.On("PrintableCommandArgs").Return("bundle check")
+	checkCmd.On("RunAndReturnTrimmedCombinedOutput").Return("Could not find cocoapods-1.11.3 in locally installed gems", errors.New("exit status 1"))
+	cmdFactory.On("Create", "bundle", []string{"_2.4.10_", "check"}, mock.Anything).Return(checkCmd)
+
+	bundleInstallCmd := new(mocks.Command)
+	bundleInstallCmd.On("PrintableCommandArgs").Return("bundle install")
+	bundleInstallCmd.On("Run").Return(nil)
+	cmdFactory.On("CreateBundleInstall", "2.4.10", mock.Anything).Return(bundleInstallCmd)
+
+	expectCommand(cmdFactory, "bundle", []string{"_2.4.10_", "exec", "pod", "--version"})
+	expectCommand(cmdFactory, "bundle", []string{"_2.4.10_", "exec", "pod", "install", "--no-repo-update"})
+
+	step := createTestStep(cmdFactory, fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	config := testRunConfig(projectDir)
+	config.GemfileLockPath = filepath.Join(projectDir, "Gemfile.lock")
+	_, err := step.Run(config)
+
+	// Then
+	require.NoError(t, err)
+	cmdFactory.AssertExpectations(t)
+	bundlerInstallCmd.AssertExpectations(t)
+	bundleInstallCmd.AssertExpectations(t)
+}
+
+const podfileLockContent = `PODS:
+  - Alamofire (5.6.4)
+
+DEPENDENCIES:
+  - Alamofire (~> 5.6)
+
+SPEC REPOS:
+  trunk:
+    - Alamofire
+
+SPEC CHECKSUMS:
+  Alamofire: 4e95d97098eacb88856099c4fc79b526a299e48c
+
+PODFILE CHECKSUM: 2e4f2e6bf3f8b4d1b0e5e4e5b3c0c9d2a7c1d5f0
+
+COCOAPODS: 1.11.3
+`
+
+const gemfileLockContent = `GEM
+  remote: https://rubygems.org/
+  specs:
+    cocoapods (1.11.3)
+      cocoapods-core (= 1.11.3)
+    cocoapods-core (1.11.3)
+
+PLATFORMS
+  ruby
+
+DEPENDENCIES
+  cocoapods (= 1.11.3)
+
+BUNDLED WITH
+   2.4.10
+`
+
+func Test_GivenReportsEnabled_WhenWritingReports_ThenWritesArtifacts(t *testing.T) {
+	// Given
+	projectDir := createTestProject(t, map[string]string{
+		"Podfile":      "platform :ios, '13.0'\n",
+		"Podfile.lock": podfileLockWithSourcesContent,
+	})
+	outputDir := filepath.Join(t.TempDir(), "deploy")
+	step := createTestStep(new(mocks.CommandFactory), fakeRubyEnvironment{}, &fakeTracker{})
+
+	// When
+	var result Result
+	err := step.writeReports(Config{
+		PodfileDir:      projectDir,
+		DependencyGraph: true,
+		WhyPods:         []string{"FirebaseCore"},
+		SBOMFormat:      sbomFormatCycloneDXAndSPDX,
+		OutputDir:       outputDir,
+	}, &result)
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(outputDir, "pods_dependency_graph.json"), result.DependencyGraphJSONPath)
+	require.Equal(t, filepath.Join(outputDir, "pods_dependency_graph.dot"), result.DependencyGraphDOTPath)
+	require.FileExists(t, result.DependencyGraphJSONPath)
+	require.FileExists(t, result.DependencyGraphDOTPath)
+	require.Equal(t, filepath.Join(outputDir, "pods.cdx.json"), result.SBOMPath)
+	require.Equal(t, filepath.Join(outputDir, "pods.spdx.json"), result.SPDXSBOMPath)
+	require.FileExists(t, result.SBOMPath)
+	require.FileExists(t, result.SPDXSBOMPath)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-utils/v2/env"
+)
+
+const (
+	timeoutKindTotal    = "timeout"
+	timeoutKindNoOutput = "no-output-timeout"
+)
+
+// CommandTimeouts configures when the watchdog kills a command, zero values disable the given check.
+type CommandTimeouts struct {
+	Timeout         time.Duration
+	NoOutputTimeout time.Duration
+}
+
+// IsSet ...
+func (t CommandTimeouts) IsSet() bool {
+	return t.Timeout > 0 || t.NoOutputTimeout > 0
+}
+
+// CommandTimeoutError is returned if the watchdog killed the command.
+type CommandTimeoutError struct {
+	Command string
+	Kind    string
+	Timeout time.Duration
+}
+
+func (e *CommandTimeoutError) Error() string {
+	if e.Kind == timeoutKindNoOutput {
+		return fmt.Sprintf("command killed after %s without output (%s)", e.Timeout, e.Command)
+	}
+	return fmt.Sprintf("command timed out after %s (%s)", e.Timeout, e.Command)
+}
+
+// isCommandTimeoutError ...
+func isCommandTimeoutError(err error) bool {
+	var timeoutErr *CommandTimeoutError
+	return errors.As(err, &timeoutErr)
+}
+
+type watchdogCommandFactory struct {
+	envRepository env.Repository
+	timeouts      CommandTimeouts
+}
+
+// NewWatchdogCommandFactory returns a command.Factory, which creates commands that are killed together with their child processes
+// if they run longer than the timeout, or do not write to their outputs for the no output timeout.
+func NewWatchdogCommandFactory(envRepository env.Repository, timeouts CommandTimeouts) command.Factory {
+	return watchdogCommandFactory{envRepository: envRepository, timeouts: timeouts}
+}
+
+// Create ...
+func (f watchdogCommandFactory) Create(name string, args []string, opts *command.Opts) command.Command {
+	cmd := exec.Command(name, args...)
+	// The command runs in its own process group, so that the whole process tree (for example a git clone started by pod install) can be killed.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	// Do not wait forever for the output copying goroutines, if a killed process left an orphan holding the output pipes.
+	cmd.WaitDelay = 10 * time.Second
+
+	c := &watchdogCommand{cmd: cmd, timeouts: f.timeouts}
+	if opts != nil {
+		cmd.Stdout = opts.Stdout
+		cmd.Stderr = opts.Stderr
+		cmd.Stdin = opts.Stdin
+		cmd.Env = append(f.envRepository.List(), opts.Env...)
+		cmd.Dir = opts.Dir
+		c.errorFinder = opts.ErrorFinder
+	}
+	return c
+}
+
+type watchdogCommand struct {
+	cmd         *exec.Cmd
+	timeouts    CommandTimeouts
+	errorFinder command.ErrorFinder
+
+	lastOutput   atomic.Int64
+	watchers     []*outputWatcher
+	errorLinesMu sync.Mutex
+	errorLines   []string
+	stop         chan struct{}
+	timedOut     chan *CommandTimeoutError
+}
+
+// PrintableCommandArgs ...
+func (c *watchdogCommand) PrintableCommandArgs() string {
+	var args []string
+	for i, arg := range c.cmd.Args {
+		if i > 0 {
+			arg = fmt.Sprintf("\"%s\"", arg)
+		}
+		args = append(args, arg)
+	}
+	return strings.Join(args, " ")
+}
+
+// Run ...
+func (c *watchdogCommand) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// RunAndReturnExitCode ...
+func (c *watchdogCommand) RunAndReturnExitCode() (int, error) {
+	err := c.Run()
+	return c.cmd.ProcessState.ExitCode(), err
+}
+
+// RunAndReturnTrimmedOutput ...
+func (c *watchdogCommand) RunAndReturnTrimmedOutput() (string, error) {
+	var out bytes.Buffer
+	c.cmd.Stdout = &out
+	err := c.Run()
+	return strings.TrimSpace(out.String()), err
+}
+
+// RunAndReturnTrimmedCombinedOutput ...
+func (c *watchdogCommand) RunAndReturnTrimmedCombinedOutput() (string, error) {
+	var out bytes.Buffer
+	c.cmd.Stdout = &out
+	c.cmd.Stderr = &out
+	err := c.Run()
+	return strings.TrimSpace(out.String()), err
+}
+
+// Start ...
+func (c *watchdogCommand) Start() error {
+	stdout := c.watchOutput(c.cmd.Stdout)
+	stderr := stdout
+	if c.cmd.Stderr != c.cmd.Stdout {
+		stderr = c.watchOutput(c.cmd.Stderr)
+	}
+	// Using the same writer for both outputs makes exec share a single pipe, so the writes are not concurrent.
+	c.cmd.Stdout = stdout
+	c.cmd.Stderr = stderr
+
+	c.lastOutput.Store(time.Now().UnixNano())
+	if err := c.cmd.Start(); err != nil {
+		return c.wrapError(err)
+	}
+
+	c.stop = make(chan struct{})
+	c.timedOut = make(chan *CommandTimeoutError, 1)
+	go c.watch()
+
+	return nil
+}
+
+// Wait ...
+func (c *watchdogCommand) Wait() error {
+	err := c.cmd.Wait()
+
+	for _, watcher := range c.watchers {
+		watcher.flush()
+	}
+
+	close(c.stop)
+	if timeoutErr := <-c.timedOut; timeoutErr != nil {
+		return timeoutErr
+	}
+
+	if err != nil {
+		return c.wrapError(err)
+	}
+	return nil
+}
+
+func (c *watchdogCommand) watch() {
+	start := time.Now()
+	ticker := time.NewTicker(c.checkInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			c.timedOut <- nil
+			return
+		case now := <-ticker.C:
+			var timeoutErr *CommandTimeoutError
+			if c.timeouts.Timeout > 0 && now.Sub(start) >= c.timeouts.Timeout {
+				timeoutErr = &CommandTimeoutError{Command: c.PrintableCommandArgs(), Kind: timeoutKindTotal, Timeout: c.timeouts.Timeout}
+			} else if c.timeouts.NoOutputTimeout > 0 && now.Sub(time.Unix(0, c.lastOutput.Load())) >= c.timeouts.NoOutputTimeout {
+				timeoutErr = &CommandTimeoutError{Command: c.PrintableCommandArgs(), Kind: timeoutKindNoOutput, Timeout: c.timeouts.NoOutputTimeout}
+			}
+
+			if timeoutErr != nil {
+				// The negative pid addresses the process group.
+				_ = syscall.Kill(-c.cmd.Process.Pid, syscall.SIGKILL)
+				<-c.stop
+				c.timedOut <- timeoutErr
+				return
+			}
+		}
+	}
+}
+
+func (c *watchdogCommand) checkInterval() time.Duration {
+	interval := time.Second
+	for _, timeout := range []time.Duration{c.timeouts.Timeout, c.timeouts.NoOutputTimeout} {
+		if timeout > 0 && timeout/10 < interval {
+			interval = timeout / 10
+		}
+	}
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	return interval
+}
+
+func (c *watchdogCommand) watchOutput(w io.Writer) io.Writer {
+	watcher := &outputWatcher{command: c, writer: w}
+	c.watchers = append(c.watchers, watcher)
+	return watcher
+}
+
+func (c *watchdogCommand) wrapError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		c.errorLinesMu.Lock()
+		defer c.errorLinesMu.Unlock()
+
+		if len(c.errorLines) > 0 {
+			return fmt.Errorf("command failed with exit status %d (%s): %w", exitErr.ExitCode(), c.PrintableCommandArgs(), errors.New(strings.Join(c.errorLines, "\n")))
+		}
+		return fmt.Errorf("command failed with exit status %d (%s): %w", exitErr.ExitCode(), c.PrintableCommandArgs(), errors.New("check the command's output for details"))
+	}
+	return fmt.Errorf("executing command failed (%s): %w", c.PrintableCommandArgs(), err)
+}
+
+// outputWatcher records the time of the last output and collects the error lines.
+// The error finder gets whole lines only: a line split across writes is kept until its end is written.
+type outputWatcher struct {
+	command *watchdogCommand
+	writer  io.Writer
+	pending []byte
+}
+
+func (w *outputWatcher) Write(p []byte) (int, error) {
+	w.command.lastOutput.Store(time.Now().UnixNano())
+
+	if w.command.errorFinder != nil {
+		// Stdout and stderr are written from separate goroutines, and error finders may keep state.
+		w.command.errorLinesMu.Lock()
+		w.pending = append(w.pending, p...)
+		if i := bytes.LastIndexByte(w.pending, '\n'); i >= 0 {
+			w.command.errorLines = append(w.command.errorLines, w.command.errorFinder(string(w.pending[:i+1]))...)
+			w.pending = append([]byte{}, w.pending[i+1:]...)
+		}
+		w.command.errorLinesMu.Unlock()
+	}
+
+	if w.writer == nil {
+		return len(p), nil
+	}
+	return w.writer.Write(p)
+}
+
+// flush passes the last, unterminated line to the error finder once the command exited.
+func (w *outputWatcher) flush() {
+	if w.command.errorFinder == nil {
+		return
+	}
+
+	w.command.errorLinesMu.Lock()
+	defer w.command.errorLinesMu.Unlock()
+
+	if len(w.pending) > 0 {
+		w.command.errorLines = append(w.command.errorLines, w.command.errorFinder(string(w.pending))...)
+		w.pending = nil
+	}
+}
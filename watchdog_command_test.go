@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-utils/v2/env"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GivenSilentCommand_WhenNoOutputTimeoutElapses_ThenKillsProcessTree(t *testing.T) {
+	// Given
+	markerPth := filepath.Join(t.TempDir(), "marker")
+	factory := NewWatchdogCommandFactory(env.NewRepository(), CommandTimeouts{NoOutputTimeout: 300 * time.Millisecond})
+	// The child process would create the marker file, if it was not killed together with the shell.
+	cmd := factory.Create("sh", []string{"-c", "(sleep 3; touch " + markerPth + ") & wait"}, nil)
+
+	// When
+	start := time.Now()
+	err := cmd.Run()
+
+	// Then
+	var timeoutErr *CommandTimeoutError
+	require.True(t, errors.As(err, &timeoutErr))
+	require.Equal(t, timeoutKindNoOutput, timeoutErr.Kind)
+	require.Less(t, time.Since(start), 2*time.Second)
+
+	// Wait until well after the child process would have created the marker file.
+	time.Sleep(time.Until(start.Add(4 * time.Second)))
+	_, statErr := os.Stat(markerPth)
+	require.True(t, os.IsNotExist(statErr))
+}
+
+func Test_GivenCommandWithOutput_WhenTimeoutElapses_ThenFailsWithTimeout(t *testing.T) {
+	// Given
+	// The no output timeout is long enough not to be reached between the ticks, even on a loaded machine.
+	factory := NewWatchdogCommandFactory(env.NewRepository(), CommandTimeouts{Timeout: 500 * time.Millisecond, NoOutputTimeout: time.Minute})
+	cmd := factory.Create("sh", []string{"-c", "while true; do echo tick; sleep 0.05; done"}, &command.Opts{})
+
+	// When
+	err := cmd.Run()
+
+	// Then
+	require.EqualError(t, err, `command timed out after 500ms (sh "-c" "while true; do echo tick; sleep 0.05; done")`)
+	require.True(t, isCommandTimeoutError(err))
+}
+
+func Test_GivenFastCommand_WhenRunning_ThenReturnsOutput(t *testing.T) {
+	// Given
+	factory := NewWatchdogCommandFactory(env.NewRepository(), CommandTimeouts{NoOutputTimeout: time.Minute})
+	cmd := factory.Create("sh", []string{"-c", "echo $GREETING; echo error >&2"}, &command.Opts{Env: []string{"GREETING=hello"}})
+
+	// When
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+
+	// Then
+	require.NoError(t, err)
+	require.Equal(t, "hello\nerror", out)
+}
+
+func Test_GivenFailingCommand_WhenRunning_ThenReturnsFoundErrors(t *testing.T) {
+	// Given
+	factory := NewWatchdogCommandFactory(env.NewRepository(), CommandTimeouts{Timeout: time.Minute})
+	errorFinder := &cocoapodsCmdErrorFinder{}
+	cmd := factory.Create("sh", []string{"-c", "echo '[!] Error installing boost'; exit 1"}, &command.Opts{ErrorFinder: errorFinder.findErrors})
+
+	// When
+	err := cmd.Run()
+
+	// Then
+	require.EqualError(t, err, `command failed with exit status 1 (sh "-c" "echo '[!] Error installing boost'; exit 1"): [!] Error installing boost`)
+	require.False(t, isCommandTimeoutError(err))
+}
+
+func Test_GivenErrorLinesSplitAcrossWrites_WhenWatchingOutput_ThenFindsEachLineOnce(t *testing.T) {
+	// Given
+	errorFinder := &cocoapodsCmdErrorFinder{}
+	cmd := &watchdogCommand{errorFinder: errorFinder.findErrors}
+	watcher := cmd.watchOutput(nil).(*outputWatcher)
+
+	// When
+	for _, chunk := range []string{"Analyzing dependencies\n[!] Error ins", "talling boost\n", "[!] Unable to", " find a specification"} {
+		_, err := watcher.Write([]byte(chunk))
+		require.NoError(t, err)
+	}
+	watcher.flush()
+
+	// Then
+	require.Equal(t, []string{"[!] Error installing boost", "[!] Unable to find a specification"}, cmd.errorLines)
+}